@@ -0,0 +1,321 @@
+package mres
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	defaultArchiveMaxDepth     = 3
+	defaultArchiveMaxEntrySize = 100 * 1024 * 1024
+	defaultArchiveMaxTotalSize = 1024 * 1024 * 1024
+	defaultArchiveMaxEntries   = 100000
+
+	// archiveSeparator separates the archive path and the entry name in result paths, e.g. release.zip!/config/app.yaml
+	archiveSeparator = "!/"
+)
+
+type (
+	// ArchiveOptions controls scanning inside archives, set it with Scanner.SetArchiveOptions.
+	// The limits protect against archive bombs, zero values use the defaults.
+	ArchiveOptions struct {
+		// Enabled scans the entries of zip, jar, war, ear, apk, tar, tar.gz and tar.bz2 archives
+		// instead of their raw content. File and content rules are applied to the entries.
+		Enabled bool `json:"enabled,omitempty"`
+		// MaxDepth is the maximum nesting of archives in archives, defaults to 3
+		MaxDepth int `json:"max_depth,omitempty"`
+		// MaxEntrySize is the maximum uncompressed size of an entry in bytes, defaults to 100MB
+		MaxEntrySize int64 `json:"max_entry_size,omitempty"`
+		// MaxTotalSize is the maximum uncompressed size of all entries of an archive in bytes, defaults to 1GB
+		MaxTotalSize int64 `json:"max_total_size,omitempty"`
+		// MaxEntries is the maximum number of entries of an archive, defaults to 100000
+		MaxEntries int `json:"max_entries,omitempty"`
+	}
+
+	archiveFormat int
+
+	// archiveScan holds the state of the scan of a single archive file and the archives nested in it
+	archiveScan struct {
+		s         *Scanner
+		ctx       context.Context
+		opts      ArchiveOptions
+		job       scanJob
+		bufPool   []byte
+		fmResultC chan<- FileMatchResult
+		cmResultC chan<- ContentMatchResult
+		errorC    chan<- error
+		total     int64
+		entries   int
+	}
+
+	// limitedReader fails with ErrArchiveLimitExceeded instead of stopping silently once the limit is crossed
+	limitedReader struct {
+		r        io.Reader
+		name     string
+		left     int64
+		maxEntry int64
+		total    *int64
+		max      int64
+	}
+)
+
+const (
+	formatNone archiveFormat = iota
+	formatZip
+	formatTar
+	formatTarGz
+	formatTarBz2
+)
+
+// SetArchiveOptions sets the options used while scanning archives
+func (s *Scanner) SetArchiveOptions(opts ArchiveOptions) {
+	if opts.MaxDepth <= 0 {
+		opts.MaxDepth = defaultArchiveMaxDepth
+	}
+	if opts.MaxEntrySize <= 0 {
+		opts.MaxEntrySize = defaultArchiveMaxEntrySize
+	}
+	if opts.MaxTotalSize <= 0 {
+		opts.MaxTotalSize = defaultArchiveMaxTotalSize
+	}
+	if opts.MaxEntries <= 0 {
+		opts.MaxEntries = defaultArchiveMaxEntries
+	}
+	s.archiveOptions = opts
+}
+
+// getArchiveFormat detects the archive format by the file extension
+func getArchiveFormat(name string) archiveFormat {
+	lower := strings.ToLower(name)
+	switch {
+	case hasAnySuffix(lower, ".zip", ".jar", ".war", ".ear", ".apk"):
+		return formatZip
+	case hasAnySuffix(lower, ".tar"):
+		return formatTar
+	case hasAnySuffix(lower, ".tar.gz", ".tgz"):
+		return formatTarGz
+	case hasAnySuffix(lower, ".tar.bz2", ".tbz2", ".tbz"):
+		return formatTarBz2
+	}
+	return formatNone
+}
+
+func hasAnySuffix(s string, suffixes ...string) bool {
+	for _, suffix := range suffixes {
+		if strings.HasSuffix(s, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// scanArchiveFile scans the entries of the archive at job.path
+func (s *Scanner) scanArchiveFile(
+	ctx context.Context, job scanJob, format archiveFormat, open fileOpener, bufPool []byte,
+	fmResultC chan<- FileMatchResult, cmResultC chan<- ContentMatchResult, errorC chan<- error) {
+	fp, err := open(job)
+	if err != nil {
+		errorC <- err
+		return
+	}
+	defer fp.Close()
+	a := &archiveScan{
+		s:         s,
+		ctx:       ctx,
+		opts:      s.archiveOptions,
+		job:       job,
+		bufPool:   bufPool,
+		fmResultC: fmResultC,
+		cmResultC: cmResultC,
+		errorC:    errorC,
+	}
+	if format != formatZip {
+		a.scanTar(job.path, fp, format, 1)
+		return
+	}
+	fi, err := fp.Stat()
+	if err != nil {
+		errorC <- err
+		return
+	}
+	if ra, ok := fp.(io.ReaderAt); ok {
+		a.scanZip(job.path, ra, fi.Size(), 1)
+		return
+	}
+	// zip needs random access, files without it are read into memory within the archive size limit
+	content, err := io.ReadAll(io.LimitReader(fp, a.opts.MaxTotalSize+1))
+	if err != nil {
+		errorC <- err
+		return
+	}
+	if int64(len(content)) > a.opts.MaxTotalSize {
+		errorC <- fmt.Errorf("%w: archive: %s is larger than %d bytes", ErrArchiveLimitExceeded, job.path, a.opts.MaxTotalSize)
+		return
+	}
+	a.scanZip(job.path, bytes.NewReader(content), int64(len(content)), 1)
+}
+
+func (a *archiveScan) scanZip(name string, ra io.ReaderAt, size int64, depth int) {
+	zr, err := zip.NewReader(ra, size)
+	if err != nil {
+		a.errorC <- fmt.Errorf("error: %w while reading zip archive: %s", err, name)
+		return
+	}
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		entryName := name + archiveSeparator + f.Name
+		if !a.nextEntry(entryName) {
+			return
+		}
+		if f.UncompressedSize64 > uint64(a.opts.MaxEntrySize) {
+			a.errorC <- fmt.Errorf("%w: entry: %s is larger than %d bytes", ErrArchiveLimitExceeded, entryName, a.opts.MaxEntrySize)
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			a.errorC <- fmt.Errorf("error: %w while reading zip entry: %s", err, entryName)
+			continue
+		}
+		ok := a.scanEntry(entryName, rc, depth)
+		rc.Close()
+		if !ok {
+			return
+		}
+	}
+}
+
+func (a *archiveScan) scanTar(name string, r io.Reader, format archiveFormat, depth int) {
+	switch format {
+	case formatTarGz:
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			a.errorC <- fmt.Errorf("error: %w while reading gzip archive: %s", err, name)
+			return
+		}
+		defer gr.Close()
+		r = gr
+	case formatTarBz2:
+		r = bzip2.NewReader(r)
+	}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			a.errorC <- fmt.Errorf("error: %w while reading tar archive: %s", err, name)
+			return
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		entryName := name + archiveSeparator + hdr.Name
+		if !a.nextEntry(entryName) {
+			return
+		}
+		if hdr.Size > a.opts.MaxEntrySize {
+			a.errorC <- fmt.Errorf("%w: entry: %s is larger than %d bytes", ErrArchiveLimitExceeded, entryName, a.opts.MaxEntrySize)
+			continue
+		}
+		if !a.scanEntry(entryName, tr, depth) {
+			return
+		}
+	}
+}
+
+// nextEntry counts the entry and returns false if the archive should not be scanned further
+func (a *archiveScan) nextEntry(entryName string) bool {
+	if a.ctx.Err() != nil {
+		return false
+	}
+	a.entries++
+	if a.entries > a.opts.MaxEntries {
+		a.errorC <- fmt.Errorf("%w: more than %d entries, stopped at: %s", ErrArchiveLimitExceeded, a.opts.MaxEntries, entryName)
+		return false
+	}
+	return true
+}
+
+// scanEntry applies the rules to an archive entry or descends into it if it is an archive itself.
+// It returns false if the archive should not be scanned further.
+func (a *archiveScan) scanEntry(entryName string, r io.Reader, depth int) bool {
+	for _, res := range a.s.fileMatchers.matchAll(entryName) {
+		a.fmResultC <- a.job.fileResult(res)
+	}
+	lr := &limitedReader{
+		r:        r,
+		name:     entryName,
+		left:     a.opts.MaxEntrySize,
+		maxEntry: a.opts.MaxEntrySize,
+		total:    &a.total,
+		max:      a.opts.MaxTotalSize,
+	}
+	if format := getArchiveFormat(entryName); format != formatNone {
+		if depth >= a.opts.MaxDepth {
+			a.errorC <- fmt.Errorf("%w: archive: %s is nested deeper than %d", ErrArchiveLimitExceeded, entryName, a.opts.MaxDepth)
+			return true
+		}
+		if format != formatZip {
+			a.scanTar(entryName, lr, format, depth+1)
+			return a.total <= a.opts.MaxTotalSize
+		}
+		// zip needs random access, nested ones are read into memory within the entry size limit
+		content, err := io.ReadAll(lr)
+		if err != nil {
+			a.errorC <- err
+			return a.total <= a.opts.MaxTotalSize
+		}
+		a.scanZip(entryName, bytes.NewReader(content), int64(len(content)), depth+1)
+		return a.total <= a.opts.MaxTotalSize
+	}
+	var cr io.Reader = lr
+	if a.s.decompressOptions.Enabled {
+		dr, closeFn := a.s.decompressReader(entryName, lr)
+		defer closeFn()
+		cr = dr
+	}
+	applicableMatchers := a.s.contentMatchers.filterApplicable(entryName)
+	if len(applicableMatchers) == 0 {
+		return a.total <= a.opts.MaxTotalSize
+	}
+	results, err := a.s.matchDecoded(applicableMatchers, entryName, cr, a.bufPool)
+	for _, res := range results {
+		a.cmResultC <- a.job.contentResult(res)
+	}
+	if err != nil {
+		a.errorC <- err
+	}
+	return a.total <= a.opts.MaxTotalSize
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.left <= 0 {
+		// the entry is exactly at the limit unless there is more to read
+		var b [1]byte
+		n, err := l.r.Read(b[:])
+		if n == 0 {
+			return 0, err
+		}
+		return 0, fmt.Errorf("%w: entry: %s is larger than %d bytes", ErrArchiveLimitExceeded, l.name, l.maxEntry)
+	}
+	if *l.total > l.max {
+		return 0, fmt.Errorf("%w: archive is larger than %d bytes, stopped at: %s", ErrArchiveLimitExceeded, l.max, l.name)
+	}
+	if int64(len(p)) > l.left {
+		p = p[:l.left]
+	}
+	n, err := l.r.Read(p)
+	l.left -= int64(n)
+	*l.total += int64(n)
+	return n, err
+}
@@ -0,0 +1,183 @@
+package mres
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func newTestZip(t *testing.T, files map[string][]byte) []byte {
+	buf := new(bytes.Buffer)
+	zw := zip.NewWriter(buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("error while creating zip entry. err: %v", err)
+		}
+		w.Write(content)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("error while writing zip. err: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func newTestTarGz(t *testing.T, files map[string][]byte) []byte {
+	buf := new(bytes.Buffer)
+	gw := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gw)
+	for name, content := range files {
+		hdr := &tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("error while writing tar header. err: %v", err)
+		}
+		tw.Write(content)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("error while writing tar. err: %v", err)
+	}
+	gw.Close()
+	return buf.Bytes()
+}
+
+func TestScanner_ScanArchives(t *testing.T) {
+	root := newTestTree(t, map[string]string{})
+	defer os.RemoveAll(root)
+	nested := newTestTarGz(t, map[string][]byte{
+		"conf/db.yaml": []byte("user: admin\npassword: secret-db"),
+	})
+	release := newTestZip(t, map[string][]byte{
+		"config/app.yaml": []byte("token: secret-app"),
+		"readme.txt":      []byte("nothing here"),
+		"backup.tar.gz":   nested,
+	})
+	if err := ioutil.WriteFile(filepath.Join(root, "release.zip"), release, 0644); err != nil {
+		t.Fatalf("error while writing archive. err: %v", err)
+	}
+	exps := Expressions{
+		FileMatchExps:    []FileMatchExp{{ID: "yaml", Exp: `\.yaml$`}},
+		ContentMatchExps: []ContentMatchExp{{ID: "secret", Exp: `secret-\w+`}},
+	}
+	tests := []struct {
+		name      string
+		opts      ArchiveOptions
+		wantFiles []string
+		wantMatch []string
+		wantErr   error
+	}{
+		{
+			// the raw content of the archive is scanned, its matches depend on the compression
+			name:      "archives disabled",
+			opts:      ArchiveOptions{},
+			wantFiles: []string{},
+			wantMatch: nil,
+		},
+		{
+			name: "archives enabled",
+			opts: ArchiveOptions{Enabled: true},
+			wantFiles: []string{
+				"release.zip!/backup.tar.gz!/conf/db.yaml",
+				"release.zip!/config/app.yaml",
+			},
+			wantMatch: []string{
+				"release.zip!/backup.tar.gz!/conf/db.yaml:2:secret-db",
+				"release.zip!/config/app.yaml:1:secret-app",
+			},
+		},
+		{
+			name:      "nesting limit",
+			opts:      ArchiveOptions{Enabled: true, MaxDepth: 1},
+			wantFiles: []string{"release.zip!/config/app.yaml"},
+			wantMatch: []string{"release.zip!/config/app.yaml:1:secret-app"},
+			wantErr:   ErrArchiveLimitExceeded,
+		},
+		{
+			name:      "entry size limit",
+			opts:      ArchiveOptions{Enabled: true, MaxEntrySize: 12},
+			wantFiles: []string{},
+			wantMatch: []string{},
+			wantErr:   ErrArchiveLimitExceeded,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := NewScanner(exps)
+			s.SetArchiveOptions(tt.opts)
+			got, errs := s.Scan(context.TODO(), []string{root}, 1)
+			files := make([]string, 0)
+			for _, r := range got.FileMatches {
+				rel, _ := filepath.Rel(root, r.FilePath)
+				files = append(files, rel)
+			}
+			matches := make([]string, 0)
+			for _, r := range got.ContentMatches {
+				rel, _ := filepath.Rel(root, r.FilePath)
+				matches = append(matches, fmt.Sprintf("%s:%d:%s", rel, r.LineNumber, r.MatchString))
+			}
+			sort.Strings(files)
+			sort.Strings(matches)
+			if !reflect.DeepEqual(files, tt.wantFiles) {
+				t.Errorf("Scanner.Scan() file matches = %v, want %v", files, tt.wantFiles)
+			}
+			if tt.wantMatch != nil && !reflect.DeepEqual(matches, tt.wantMatch) {
+				t.Errorf("Scanner.Scan() content matches = %v, want %v", matches, tt.wantMatch)
+			}
+			if tt.wantErr == nil && len(errs) > 0 {
+				t.Errorf("Scanner.Scan() errors = %v", errs)
+			}
+			if tt.wantErr != nil && (len(errs) == 0 || !errors.Is(errs[0], tt.wantErr)) {
+				t.Errorf("Scanner.Scan() errors = %v, want %v", errs, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestScanner_ScanNestedZipTotalSize(t *testing.T) {
+	root := newTestTree(t, map[string]string{})
+	defer os.RemoveAll(root)
+	inner := newTestZip(t, map[string][]byte{
+		"big.txt": []byte(strings.Repeat("secret-inner\n", 200)),
+	})
+	// the entries are written in order, the one after the nested zip must not be scanned
+	buf := new(bytes.Buffer)
+	zw := zip.NewWriter(buf)
+	for _, entry := range []struct {
+		name    string
+		content []byte
+	}{{"inner.zip", inner}, {"z.yaml", []byte("token: secret-outer")}} {
+		w, err := zw.Create(entry.name)
+		if err != nil {
+			t.Fatalf("error while creating zip entry. err: %v", err)
+		}
+		w.Write(entry.content)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("error while writing zip. err: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(root, "outer.zip"), buf.Bytes(), 0644); err != nil {
+		t.Fatalf("error while writing archive. err: %v", err)
+	}
+	s, _ := NewScanner(Expressions{
+		FileMatchExps:    []FileMatchExp{{ID: "yaml", Exp: `\.yaml$`}},
+		ContentMatchExps: []ContentMatchExp{{ID: "secret", Exp: `secret-\w+`}},
+	})
+	s.SetArchiveOptions(ArchiveOptions{Enabled: true, MaxTotalSize: 1500})
+	got, errs := s.Scan(context.TODO(), []string{root}, 1)
+	if len(got.FileMatches) > 0 {
+		t.Errorf("Scanner.Scan() file matches = %v, want none after the total size limit", got.FileMatches)
+	}
+	if len(errs) == 0 || !errors.Is(errs[0], ErrArchiveLimitExceeded) {
+		t.Errorf("Scanner.Scan() errors = %v, want %v", errs, ErrArchiveLimitExceeded)
+	}
+}
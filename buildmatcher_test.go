@@ -1,6 +1,7 @@
 package mres
 
 import (
+	"reflect"
 	"testing"
 )
 
@@ -131,7 +132,7 @@ func Test_buildContentMatchers(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			matchers, errs := buildContentMatchers(tt.args.exps)
+			matchers, errs := buildContentMatchers(tt.args.exps, nil)
 			if len(matchers) != tt.matchersLen {
 				t.Errorf("buildContentMatchers() matchers = %v, want %v", len(matchers), tt.matchersLen)
 			}
@@ -159,3 +160,60 @@ func newContentMatchExp(id string, fileFilter bool, fileFilterExp string, exp st
 		Exp: exp,
 	}
 }
+
+func Test_buildContentMatchers_fileFilters(t *testing.T) {
+	filters, errs := buildFileFilters([]FileMatchExp{
+		{ID: "source", Exp: `\.(go|py)$`},
+		{ID: "not-vendor", Exp: `^vendor/`, FlipMatch: true},
+		{ID: "broken", Exp: `(`},
+	})
+	if len(filters) != 2 || len(errs) != 1 {
+		t.Fatalf("buildFileFilters() filters = %v, errs = %v, want 2 and 1", len(filters), len(errs))
+	}
+	tests := []struct {
+		name    string
+		exps    []ContentMatchExp
+		wantErr bool
+	}{
+		{name: "named filter", exps: []ContentMatchExp{{ID: "id1", Exp: "todo", FileFilter: "source"}}},
+		{name: "unknown filter", exps: []ContentMatchExp{{ID: "id1", Exp: "todo", FileFilter: "docs"}}, wantErr: true},
+		{name: "own filter", exps: []ContentMatchExp{newContentMatchExp("id1", true, `\.go$`, "todo")}},
+		{name: "both filters set", exps: []ContentMatchExp{{ID: "id1", Exp: "todo", FileFilter: "source", FileFilterEnabled: true, FileMatchExp: FileMatchExp{Exp: `\.go$`}}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, errs := buildContentMatchers(tt.exps, filters)
+			if (len(errs) > 0) != tt.wantErr {
+				t.Errorf("buildContentMatchers() errs = %v, wantErr %v", errs, tt.wantErr)
+			}
+		})
+	}
+
+	matchers, errs := buildContentMatchers([]ContentMatchExp{
+		{ID: "todo", Exp: "todo", FileFilter: "source"},
+		{ID: "fixme", Exp: "fixme", FileFilter: "source"},
+		{ID: "secret", Exp: "secret", FileFilter: "not-vendor"},
+		{ID: "any", Exp: "any"},
+	}, filters)
+	if len(errs) > 0 {
+		t.Fatalf("buildContentMatchers() errs = %v", errs)
+	}
+	if matchers[0].fileMatcher != matchers[1].fileMatcher {
+		t.Errorf("buildContentMatchers() rules referring to the same filter got different matchers")
+	}
+	applicable := map[string][]string{
+		"main.go":         {"todo", "fixme", "secret", "any"},
+		"vendor/x/y.go":   {"todo", "fixme", "any"},
+		"README.md":       {"secret", "any"},
+		"vendor/notes.md": {"any"},
+	}
+	for path, want := range applicable {
+		got := make([]string, 0)
+		for _, m := range matchers.filterApplicable(path) {
+			got = append(got, m.ID)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("filterApplicable(%v) = %v, want %v", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package mres
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// cacheVersion is bumped when the format of the cache file changes, older files are discarded
+const cacheVersion = 1
+
+type (
+	// ScanCache remembers the content match results of the scanned files so unchanged files are
+	// not scanned again. Open it with OpenScanCache, set it with Scanner.SetCache and call Save once done.
+	// All the entries are dropped when the expressions or the options affecting the results change.
+	ScanCache struct {
+		path      string
+		mu        sync.Mutex
+		rulesHash string
+		entries   map[string]cacheEntry
+		// seen are the files scanned since the cache was opened, the entries of the others are not saved
+		seen map[string]struct{}
+	}
+
+	// cacheFile is the JSON layout of the cache file
+	cacheFile struct {
+		Version   int                   `json:"version"`
+		RulesHash string                `json:"rules_hash"`
+		Entries   map[string]cacheEntry `json:"entries"`
+	}
+
+	cacheEntry struct {
+		Size           int64                `json:"size"`
+		ModTime        int64                `json:"mod_time"`
+		Hash           string               `json:"hash"`
+		ContentMatches []ContentMatchResult `json:"content_matches,omitempty"`
+	}
+
+	// hashingFile feeds everything read from the file to a hash, the hash covers the whole file
+	// once the end of the file is read
+	hashingFile struct {
+		fs.File
+		h   hash.Hash
+		err error
+		eof bool
+	}
+)
+
+// OpenScanCache loads the cache file at path, a missing file gives an empty cache
+func OpenScanCache(path string) (*ScanCache, error) {
+	c := &ScanCache{
+		path:    path,
+		entries: make(map[string]cacheEntry),
+		seen:    make(map[string]struct{}),
+	}
+	content, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	f := cacheFile{}
+	if err := json.Unmarshal(content, &f); err != nil {
+		return nil, fmt.Errorf("error: %w while parsing cache: %s", err, path)
+	}
+	if f.Version != cacheVersion || f.Entries == nil {
+		return c, nil
+	}
+	c.rulesHash = f.RulesHash
+	c.entries = f.Entries
+	return c, nil
+}
+
+// Save writes the cache to the file it was opened from. The file is replaced atomically
+// so an interrupted save does not corrupt the cache. The entries of the files which were not scanned
+// since the cache was opened, e.g. deleted files, are dropped.
+func (c *ScanCache) Save() error {
+	c.mu.Lock()
+	for path := range c.entries {
+		if _, ok := c.seen[path]; !ok {
+			delete(c.entries, path)
+		}
+	}
+	content, err := json.Marshal(cacheFile{
+		Version:   cacheVersion,
+		RulesHash: c.rulesHash,
+		Entries:   c.entries,
+	})
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error: %w while writing cache: %s", err, c.path)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error: %w while writing cache: %s", err, c.path)
+	}
+	return os.Rename(tmp.Name(), c.path)
+}
+
+// Len returns the number of cached files
+func (c *ScanCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// useRules drops all the entries if they were cached with other rules
+func (c *ScanCache) useRules(rulesHash string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.rulesHash != rulesHash {
+		c.rulesHash = rulesHash
+		c.entries = make(map[string]cacheEntry)
+	}
+}
+
+func (c *ScanCache) lookup(path string) (cacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.seen[path] = struct{}{}
+	entry, ok := c.entries[path]
+	return entry, ok
+}
+
+func (c *ScanCache) store(path string, entry cacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.seen[path] = struct{}{}
+	c.entries[path] = entry
+}
+
+// SetCache sets the cache used to skip the unchanged files of the paths to scan, nil disables it.
+// Only the files walked by Scan and ScanWithCallback are cached, archives are always scanned again.
+// A cached file whose modification time changed is read once to compare its content hash, and once
+// more to scan it if the content changed. The files where the scan stopped early because of the match
+// limits are not hashed, they are scanned again whenever their modification time changes.
+func (s *Scanner) SetCache(cache *ScanCache) {
+	s.cache = cache
+}
+
+// cacheRulesHash hashes the expressions and the options which change the content match results
+func (s *Scanner) cacheRulesHash() string {
+	h := sha256.New()
+	h.Write([]byte(s.expressionsHash))
+	json.NewEncoder(h).Encode(s.decompressOptions)
+	json.NewEncoder(h).Encode(s.matchLimits)
+	// the timeout is left out, aborted files are not cached
+	json.NewEncoder(h).Encode(s.fileLimits.MaxSize)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// hashExpressions returns the hex encoded SHA-256 of the JSON encoded expressions
+func hashExpressions(exps Expressions) string {
+	content, _ := json.Marshal(exps)
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// matchFileContentCached is matchFileContent replaying the cached results of unchanged files.
+// A file is unchanged when its size and modification time are the same, or when only the
+// modification time changed and its content hash is the same.
+func (s *Scanner) matchFileContentCached(open fileOpener, job scanJob, bufPool []byte) ([]ContentMatchResult, error) {
+	// the results of files without applicable matchers only depend on the path
+	if len(s.contentMatchers.filterApplicable(job.path)) == 0 {
+		return s.matchFileContent(open, job, bufPool)
+	}
+	fi, err := os.Stat(job.path)
+	if err != nil {
+		return s.matchFileContent(open, job, bufPool)
+	}
+	modTime := fi.ModTime().UnixNano()
+	if entry, ok := s.cache.lookup(job.path); ok && entry.Size == fi.Size() {
+		if entry.ModTime == modTime {
+			return entry.ContentMatches, nil
+		}
+		if sum, err := hashFile(open, job); err == nil && sum == entry.Hash {
+			entry.ModTime = modTime
+			s.cache.store(job.path, entry)
+			return entry.ContentMatches, nil
+		}
+	}
+	var hf *hashingFile
+	hashingOpen := func(job scanJob) (fs.File, error) {
+		fp, err := open(job)
+		if err != nil {
+			return nil, err
+		}
+		hf = &hashingFile{File: fp, h: sha256.New()}
+		return hf, nil
+	}
+	results, err := s.matchFileContent(hashingOpen, job, bufPool)
+	if err != nil {
+		// partial results are not cached so the file is scanned again
+		return results, err
+	}
+	entry := cacheEntry{
+		Size:           fi.Size(),
+		ModTime:        modTime,
+		ContentMatches: results,
+	}
+	// without a hash of the whole file, e.g. when the scan stopped early, only an unchanged
+	// modification time replays the results
+	if hf != nil && hf.err == nil && hf.eof {
+		entry.Hash = hex.EncodeToString(hf.h.Sum(nil))
+	}
+	s.cache.store(job.path, entry)
+	return results, nil
+}
+
+// hashFile returns the hex encoded SHA-256 of the content of the file of the job
+func hashFile(open fileOpener, job scanJob) (string, error) {
+	fp, err := open(job)
+	if err != nil {
+		return "", err
+	}
+	defer fp.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, fp); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (f *hashingFile) Read(p []byte) (int, error) {
+	n, err := f.File.Read(p)
+	f.h.Write(p[:n])
+	switch {
+	case err == io.EOF:
+		f.eof = true
+	case err != nil:
+		f.err = err
+	}
+	return n, err
+}
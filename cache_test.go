@@ -0,0 +1,168 @@
+package mres
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScanner_ScanWithCache(t *testing.T) {
+	root := newTestTree(t, map[string]string{
+		"config.json": "{\n  \"token\": \"xorp-aaaa\"\n}\n",
+		"src/main.go": "package main\n// xorp-bbbb\n",
+	})
+	defer os.RemoveAll(root)
+	cachePath := filepath.Join(root, "cache.json")
+	configPath := filepath.Join(root, "config.json")
+	mtime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	write := func(content string, modTime time.Time) {
+		if err := ioutil.WriteFile(configPath, []byte(content), 0644); err != nil {
+			t.Fatalf("error while writing file. err: %v", err)
+		}
+		if err := os.Chtimes(configPath, modTime, modTime); err != nil {
+			t.Fatalf("error while setting times. err: %v", err)
+		}
+	}
+	write("{\n  \"token\": \"xorp-aaaa\"\n}\n", mtime)
+	scan := func(exp string) []string {
+		cache, err := OpenScanCache(cachePath)
+		if err != nil {
+			t.Fatalf("OpenScanCache() error = %v", err)
+		}
+		s, _ := NewScanner(Expressions{ContentMatchExps: []ContentMatchExp{{ID: "xorp", Exp: exp}}})
+		s.SetCache(cache)
+		got, errs := s.Scan(context.TODO(), []string{filepath.Join(root, "config.json"), filepath.Join(root, "src")}, 2)
+		if len(errs) > 0 {
+			t.Fatalf("Scanner.Scan() errors = %v", errs)
+		}
+		if err := cache.Save(); err != nil {
+			t.Fatalf("ScanCache.Save() error = %v", err)
+		}
+		matches := make([]string, 0)
+		for _, r := range got.ContentMatches {
+			matches = append(matches, fmt.Sprintf("%s:%d:%s", filepath.Base(r.FilePath), r.LineNumber, r.MatchString))
+		}
+		sort.Strings(matches)
+		return matches
+	}
+	tests := []struct {
+		name   string
+		change func()
+		exp    string
+		want   []string
+	}{
+		{
+			name:   "first scan",
+			change: func() {},
+			exp:    `xorp-\w+`,
+			want:   []string{"config.json:2:xorp-aaaa", "main.go:2:xorp-bbbb"},
+		},
+		{
+			// the size and the modification time are the same so the cached results are replayed
+			name:   "unchanged size and time",
+			change: func() { write("{\n  \"token\": \"xorp-cccc\"\n}\n", mtime) },
+			exp:    `xorp-\w+`,
+			want:   []string{"config.json:2:xorp-aaaa", "main.go:2:xorp-bbbb"},
+		},
+		{
+			name:   "changed time and content",
+			change: func() { write("{\n  \"token\": \"xorp-dddd\"\n}\n", mtime.Add(time.Minute)) },
+			exp:    `xorp-\w+`,
+			want:   []string{"config.json:2:xorp-dddd", "main.go:2:xorp-bbbb"},
+		},
+		{
+			name:   "changed time only",
+			change: func() { write("{\n  \"token\": \"xorp-dddd\"\n}\n", mtime.Add(2*time.Minute)) },
+			exp:    `xorp-\w+`,
+			want:   []string{"config.json:2:xorp-dddd", "main.go:2:xorp-bbbb"},
+		},
+		{
+			name:   "changed rules",
+			change: func() {},
+			exp:    `xorp-d+`,
+			want:   []string{"config.json:2:xorp-dddd"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.change()
+			if got := scan(tt.exp); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Scanner.Scan() content matches = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenScanCache(t *testing.T) {
+	root := newTestTree(t, map[string]string{
+		"invalid.json": `{"entries":`,
+		"old.json":     `{"version":0,"rules_hash":"x","entries":{"a":{"size":1}}}`,
+	})
+	defer os.RemoveAll(root)
+	if _, err := OpenScanCache(filepath.Join(root, "invalid.json")); err == nil {
+		t.Errorf("OpenScanCache(invalid.json) error = nil, want error")
+	}
+	for _, name := range []string{"old.json", "missing.json"} {
+		c, err := OpenScanCache(filepath.Join(root, name))
+		if err != nil || c.Len() != 0 {
+			t.Errorf("OpenScanCache(%s) = %v entries, %v, want empty cache", name, c.Len(), err)
+		}
+	}
+}
+
+func TestScanner_ScanWithCacheEarlyStopAndPrune(t *testing.T) {
+	content := "xorp-aaaa\n" + strings.Repeat("x", 100000) + "\nxorp-bbbb\n"
+	root := newTestTree(t, map[string]string{
+		"a.txt": content,
+		"b.txt": "xorp-cccc\n",
+		"c.txt": "no match\n",
+	})
+	defer os.RemoveAll(root)
+	cachePath := filepath.Join(t.TempDir(), "cache.json")
+	scan := func() *ScanCache {
+		cache, err := OpenScanCache(cachePath)
+		if err != nil {
+			t.Fatalf("OpenScanCache() error = %v", err)
+		}
+		s, _ := NewScanner(Expressions{ContentMatchExps: []ContentMatchExp{{ID: "xorp", Exp: `xorp-\w+`}}})
+		s.SetMatchLimits(MatchLimits{FirstMatchOnly: true})
+		s.SetCache(cache)
+		if _, errs := s.Scan(context.TODO(), []string{root}, 1); len(errs) > 0 {
+			t.Fatalf("Scanner.Scan() errors = %v", errs)
+		}
+		if err := cache.Save(); err != nil {
+			t.Fatalf("ScanCache.Save() error = %v", err)
+		}
+		return cache
+	}
+	// the scan of a.txt stops at its first match, the rest is not read to hash it
+	cache := scan()
+	if entry, ok := cache.lookup(filepath.Join(root, "a.txt")); !ok || entry.Hash != "" || len(entry.ContentMatches) != 1 {
+		t.Errorf("ScanCache entry = %v, want a match and no hash", entry)
+	}
+	sum := sha256.Sum256([]byte("no match\n"))
+	if entry, ok := cache.lookup(filepath.Join(root, "c.txt")); !ok || entry.Hash != hex.EncodeToString(sum[:]) {
+		t.Errorf("ScanCache entry hash = %v, want the hash of the whole file %x", entry.Hash, sum)
+	}
+	if cache.Len() != 3 {
+		t.Fatalf("ScanCache.Len() = %v, want 3", cache.Len())
+	}
+	// the entries of deleted files are dropped when the cache is saved
+	if err := os.Remove(filepath.Join(root, "b.txt")); err != nil {
+		t.Fatal(err)
+	}
+	scan()
+	cache, err := OpenScanCache(cachePath)
+	if err != nil || cache.Len() != 2 {
+		t.Errorf("OpenScanCache() = %v entries, %v, want 2 entries", cache.Len(), err)
+	}
+}
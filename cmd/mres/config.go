@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+)
+
+const (
+	configExitOK    = 0
+	configExitError = 2
+)
+
+// runConfig runs the config subcommands and returns the exit code
+func runConfig(args []string) int {
+	if len(args) > 0 && args[0] == "dump" {
+		return runConfigDump(args[1:])
+	}
+	fmt.Fprintln(os.Stderr, "Usage: mres config dump [options]\n\nPrints the effective rules of a config.")
+	return configExitError
+}
+
+// runConfigDump prints the rules of a config with its includes, overrides and profile resolved
+func runConfigDump(args []string) int {
+	flags := flag.NewFlagSet("config dump", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: mres config dump -config <rules.json> [options]\n\nPrints the effective rules of the config in JSON, with its includes, overrides and profile resolved.")
+		flags.PrintDefaults()
+	}
+	configPathPtr := flags.String("config", "", "Relative or absolute path to the JSON config file with the expressions")
+	profilePtr := flags.String("profile", "", "Profile of the config file applied on top of its rules")
+	tagsPtr := flags.String("tags", "", "Comma separated tags, only the rules with one of the tags are printed")
+	minSeverityPtr := flags.String("min-severity", "", "Only print the rules with at least this severity")
+	if err := flags.Parse(args); err != nil {
+		return configExitError
+	}
+	if *configPathPtr == "" {
+		fmt.Fprintln(os.Stderr, "Specify the -config to dump. Check help by using -help option.")
+		return configExitError
+	}
+	exps, err := buildExpressions(*configPathPtr, *profilePtr, "", "", splitList(*tagsPtr), *minSeverityPtr)
+	if err != nil {
+		return configExitError
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(exps); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return configExitError
+	}
+	return configExitOK
+}
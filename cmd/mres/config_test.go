@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/movna/mres"
+)
+
+func TestRunConfigDump(t *testing.T) {
+	dir := t.TempDir()
+	for name, config := range map[string]string{
+		"base.json": `{"file_match_exps": [{"id": "pem", "exp": "\\.pem$"}],
+			"content_match_exps": [{"id": "aws", "exp": "AKIA\\w+", "severity": "high"}, {"id": "todo", "exp": "TODO"}]}`,
+		"rules.json": `{"include": ["base.json"], "disable": ["pem"],
+			"content_match_exps": [{"id": "todo", "exp": "TODO|FIXME", "severity": "low"}, {"id": "local", "exp": "local", "severity": "medium"}],
+			"profiles": {"ci": {"disable": ["todo"]}}}`,
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(config), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	configPath := filepath.Join(dir, "rules.json")
+	tests := []struct {
+		name     string
+		args     []string
+		wantCode int
+		want     mres.Expressions
+	}{
+		{
+			name:     "includes and disabled rules",
+			args:     []string{"-config", configPath},
+			wantCode: configExitOK,
+			want: mres.Expressions{ContentMatchExps: []mres.ContentMatchExp{
+				{ID: "aws", Exp: `AKIA\w+`, RuleMetadata: mres.RuleMetadata{Severity: mres.SeverityHigh}},
+				{ID: "todo", Exp: `TODO|FIXME`, RuleMetadata: mres.RuleMetadata{Severity: mres.SeverityLow}},
+				{ID: "local", Exp: `local`, RuleMetadata: mres.RuleMetadata{Severity: mres.SeverityMedium}},
+			}},
+		},
+		{
+			name:     "profile",
+			args:     []string{"-config", configPath, "-profile", "ci"},
+			wantCode: configExitOK,
+			want: mres.Expressions{ContentMatchExps: []mres.ContentMatchExp{
+				{ID: "aws", Exp: `AKIA\w+`, RuleMetadata: mres.RuleMetadata{Severity: mres.SeverityHigh}},
+				{ID: "local", Exp: `local`, RuleMetadata: mres.RuleMetadata{Severity: mres.SeverityMedium}},
+			}},
+		},
+		{
+			name:     "min severity",
+			args:     []string{"-config", configPath, "-min-severity", "medium"},
+			wantCode: configExitOK,
+			want: mres.Expressions{ContentMatchExps: []mres.ContentMatchExp{
+				{ID: "aws", Exp: `AKIA\w+`, RuleMetadata: mres.RuleMetadata{Severity: mres.SeverityHigh}},
+				{ID: "local", Exp: `local`, RuleMetadata: mres.RuleMetadata{Severity: mres.SeverityMedium}},
+			}},
+		},
+		{name: "unknown profile", args: []string{"-config", configPath, "-profile", "dev"}, wantCode: configExitError},
+		{name: "no config", args: []string{}, wantCode: configExitError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, code := captureStdout(t, func() int { return runConfig(append([]string{"dump"}, tt.args...)) })
+			if code != tt.wantCode {
+				t.Fatalf("runConfig(dump) = %v, want %v", code, tt.wantCode)
+			}
+			if code != configExitOK {
+				return
+			}
+			got := mres.Expressions{}
+			if err := json.Unmarshal([]byte(out), &got); err != nil {
+				t.Fatalf("runConfig(dump) output = %s, error = %v, want the expressions in JSON", out, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("runConfig(dump) = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+	if code := runConfig([]string{"show"}); code != configExitError {
+		t.Errorf("runConfig(show) = %v, want %v", code, configExitError)
+	}
+}
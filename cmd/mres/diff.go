@@ -0,0 +1,79 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/movna/mres"
+)
+
+const (
+	diffExitClean = 0
+	diffExitAdded = 1
+	diffExitError = 2
+)
+
+// runDiff compares two result files and returns the exit code, which is non-zero when there are added findings
+// so it can gate pull requests
+func runDiff(args []string) int {
+	flags := flag.NewFlagSet("diff", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: mres diff -old <results.json> -new <results.json> [options]\n\nCompares the results of two scans written with -out and exits with 1 if there are added findings.")
+		flags.PrintDefaults()
+	}
+	oldPathPtr := flags.String("old", "", "Relative or absolute path to the JSON results of the old scan")
+	newPathPtr := flags.String("new", "", "Relative or absolute path to the JSON results of the new scan")
+	oldRootPtr := flags.String("old-root", "", "Path scanned by the old scan, the file paths are compared relative to it")
+	newRootPtr := flags.String("new-root", "", "Path scanned by the new scan, the file paths are compared relative to it")
+	resultDumpPathPtr := flags.String("out", "", "Relative or absolute path to dump the added, removed and unchanged findings in JSON format")
+	unchangedPtr := flags.Bool("unchanged", false, "Print the unchanged findings too")
+	if err := flags.Parse(args); err != nil {
+		return diffExitError
+	}
+	if *oldPathPtr == "" || *newPathPtr == "" {
+		fmt.Fprintln(os.Stderr, "Specify both -old and -new. Check help by using -help option.")
+		return diffExitError
+	}
+	oldResult, err := mres.LoadMatchResult(*oldPathPtr)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return diffExitError
+	}
+	newResult, err := mres.LoadMatchResult(*newPathPtr)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return diffExitError
+	}
+	diff := mres.DiffResults(oldResult, newResult, mres.DiffOptions{OldRoot: *oldRootPtr, NewRoot: *newRootPtr})
+	printDiff("+", diff.Added)
+	printDiff("-", diff.Removed)
+	if *unchangedPtr {
+		printDiff(" ", diff.Unchanged)
+	}
+	fmt.Printf("added: %d, removed: %d, unchanged: %d\n", countResults(diff.Added), countResults(diff.Removed), countResults(diff.Unchanged))
+	if *resultDumpPathPtr != "" {
+		if err := writeJSON(*resultDumpPathPtr, diff); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return diffExitError
+		}
+	}
+	if countResults(diff.Added) > 0 {
+		return diffExitAdded
+	}
+	return diffExitClean
+}
+
+// printDiff prints the findings in a grep like format prefixed with the change
+func printDiff(prefix string, r mres.MatchResult) {
+	for _, f := range r.FileMatches {
+		fmt.Printf("%s %s: [%s]\n", prefix, f.FilePath, ruleLabel(f.ExpID, f.Rule))
+	}
+	for _, c := range r.ContentMatches {
+		fmt.Printf("%s %s:%d: [%s] %s\n", prefix, c.FilePath, c.LineNumber, ruleLabel(c.ExpID, c.Rule), c.MatchString)
+	}
+}
+
+func countResults(r mres.MatchResult) int {
+	return len(r.FileMatches) + len(r.ContentMatches)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/movna/mres"
+)
+
+func TestRunDiff(t *testing.T) {
+	dir := t.TempDir()
+	writeResult := func(name string, matches ...string) string {
+		result := mres.MatchResult{}
+		for _, m := range matches {
+			result.ContentMatches = append(result.ContentMatches, mres.ContentMatchResult{ExpID: "xorp", FilePath: "app.env", LineNumber: 1, MatchString: m})
+		}
+		content, err := json.Marshal(result)
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, content, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	baseline := writeResult("baseline.json", "xorp-1", "xorp-2")
+	corrupt := filepath.Join(dir, "corrupt.json")
+	if err := os.WriteFile(corrupt, []byte(`{"content_matches": [`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		args     []string
+		wantCode int
+		wantOut  string
+	}{
+		{name: "unchanged", args: []string{"-old", baseline, "-new", writeResult("same.json", "xorp-2", "xorp-1")}, wantCode: diffExitClean, wantOut: "added: 0, removed: 0, unchanged: 2"},
+		{name: "resolved", args: []string{"-old", baseline, "-new", writeResult("resolved.json", "xorp-1")}, wantCode: diffExitClean, wantOut: "- app.env:1: [xorp] xorp-2\nadded: 0, removed: 1, unchanged: 1"},
+		{name: "new", args: []string{"-old", baseline, "-new", writeResult("new.json", "xorp-1", "xorp-2", "xorp-3")}, wantCode: diffExitAdded, wantOut: "+ app.env:1: [xorp] xorp-3\nadded: 1, removed: 0, unchanged: 2"},
+		{name: "missing baseline", args: []string{"-old", filepath.Join(dir, "missing.json"), "-new", baseline}, wantCode: diffExitError},
+		{name: "corrupt baseline", args: []string{"-old", corrupt, "-new", baseline}, wantCode: diffExitError},
+		{name: "corrupt new results", args: []string{"-old", baseline, "-new", corrupt}, wantCode: diffExitError},
+		{name: "no new results", args: []string{"-old", baseline}, wantCode: diffExitError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, code := captureStdout(t, func() int { return runDiff(tt.args) })
+			if code != tt.wantCode {
+				t.Errorf("runDiff() = %v, want %v", code, tt.wantCode)
+			}
+			if strings.TrimSpace(out) != tt.wantOut {
+				t.Errorf("runDiff() output = %q, want %q", out, tt.wantOut)
+			}
+		})
+	}
+	// the added, removed and unchanged findings are written with -out
+	outPath := filepath.Join(dir, "diff.json")
+	captureStdout(t, func() int {
+		return runDiff([]string{"-old", baseline, "-new", writeResult("out.json", "xorp-2", "xorp-3"), "-out", outPath})
+	})
+	content, err := os.ReadFile(outPath)
+	if err != nil {
+		t.Fatalf("error while reading the diff. err: %v", err)
+	}
+	diff := mres.DiffResult{}
+	if err := json.Unmarshal(content, &diff); err != nil {
+		t.Fatalf("error while parsing the diff. err: %v", err)
+	}
+	if len(diff.Added.ContentMatches) != 1 || len(diff.Removed.ContentMatches) != 1 || len(diff.Unchanged.ContentMatches) != 1 {
+		t.Errorf("runDiff() -out = %s, want 1 added, 1 removed and 1 unchanged finding", content)
+	}
+}
@@ -2,18 +2,23 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
 	"os"
 	"os/signal"
 	"runtime"
+	"strings"
 	"time"
 
 	"github.com/movna/mres"
 	"github.com/movna/mres/internal"
 )
 
+// stdinPath is the -path value to scan the content piped to stdin
+const stdinPath = "-"
+
 var (
 	log                  = internal.NewDefaultLogger()
 	errInvalidCliOptions = errors.New("invalid cli options")
@@ -25,6 +30,16 @@ type cliOptions struct {
 	workerCount     int
 	outputToFile    bool
 	outputFilePath  string
+	walkOptions     mres.WalkOptions
+	archiveOptions  mres.ArchiveOptions
+	decompressOpts  mres.DecompressOptions
+	matchLimits     mres.MatchLimits
+	fileLimits      mres.FileLimits
+	stdinName       string
+	scanGit         bool
+	gitOptions      mres.GitScanOptions
+	cachePath       string
+	watchDebounce   time.Duration
 }
 
 func printRuntimeStats() {
@@ -42,13 +57,34 @@ func printRuntimeStats() {
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "precommit":
+			os.Exit(runPrecommit(os.Args[2:]))
+		case "test-rules":
+			os.Exit(runTestRules(os.Args[2:]))
+		case "diff":
+			os.Exit(runDiff(os.Args[2:]))
+		case "config":
+			os.Exit(runConfig(os.Args[2:]))
+		case "watch":
+			printRuntimeStats()
+			run(os.Args[2:], true)
+			return
+		}
+	}
 	printRuntimeStats()
 	Run()
 }
 
 //Run ...
 func Run() {
-	cliOptions, err := parseCliOptions()
+	run(os.Args[1:], false)
+}
+
+// run scans with the command line args, in watch mode the paths are scanned again on changes until interrupted
+func run(args []string, watch bool) {
+	cliOptions, err := parseCliOptions(args)
 	if err != nil {
 		log.Error(err, "Cannot continue further")
 		return
@@ -69,95 +105,337 @@ func Run() {
 		return
 	}
 	scanner.SetLogger(log)
+	for _, w := range scanner.LintWarnings() {
+		log.Info(w.Error())
+	}
+	scanner.SetWalkOptions(cliOptions.walkOptions)
+	scanner.SetArchiveOptions(cliOptions.archiveOptions)
+	scanner.SetDecompressOptions(cliOptions.decompressOpts)
+	scanner.SetMatchLimits(cliOptions.matchLimits)
+	scanner.SetFileLimits(cliOptions.fileLimits)
+	var cache *mres.ScanCache
+	if cliOptions.cachePath != "" {
+		cache, err = mres.OpenScanCache(cliOptions.cachePath)
+		if err != nil {
+			log.Error(err, "Cannot load the cache file")
+			return
+		}
+		scanner.SetCache(cache)
+	}
 	fmResultsCount := 0
 	cmResultsCount := 0
 	errorsCount := 0
+	skippedCount := 0
+	abortedCount := 0
+	results := mres.MatchResult{}
 	onFileMatchResult := func(r mres.FileMatchResult) {
 		fmResultsCount++
-		if !cliOptions.outputToFile {
-			log.Info(fmt.Sprintf("File match - id: %s, filepath: %s", r.ExpID, r.FilePath))
+		if cliOptions.outputToFile {
+			results.FileMatches = append(results.FileMatches, r)
+		} else {
+			log.Info(fmt.Sprintf("%sFile match - id: %s%s, filepath: %s%s%s", resolvedInfo(r.Resolved), r.ExpID, ruleInfo(r.Rule), r.FilePath, linkInfo(r.LinkPath), commitInfo(r.Commit)))
 		}
 	}
 	onContentMatchResult := func(r mres.ContentMatchResult) {
 		cmResultsCount++
-		if !cliOptions.outputToFile {
-			log.Info(fmt.Sprintf("Content match - id: %s, filepath: %s, lineno: %d, match: %s", r.ExpID, r.FilePath, r.LineNumber, r.MatchString))
+		if cliOptions.outputToFile {
+			results.ContentMatches = append(results.ContentMatches, r)
+		} else {
+			log.Info(fmt.Sprintf("%sContent match - id: %s%s, filepath: %s%s%s, lineno: %d%s, match: %s%s%s%s", resolvedInfo(r.Resolved), r.ExpID, ruleInfo(r.Rule), r.FilePath, linkInfo(r.LinkPath), commitInfo(r.Commit), r.LineNumber, keyPathInfo(r.KeyPath), r.MatchString, validationInfo(r.Validation), encodingInfo(r.Encoding), truncatedInfo(r.Truncated)))
 		}
 	}
 	onError := func(e error) {
 		errorsCount++
+		switch {
+		case errors.Is(e, mres.ErrFileTooLarge):
+			skippedCount++
+		case errors.Is(e, mres.ErrFileTimeout):
+			abortedCount++
+		}
 		if !cliOptions.outputToFile {
 			log.Error(e, "")
 		}
 	}
 	start := time.Now()
-	scanner.ScanWithCallback(ctx, cliOptions.foldersToScan, cliOptions.workerCount, onFileMatchResult, onContentMatchResult, onError)
+	switch {
+	case watch:
+		if cliOptions.scanGit || cliOptions.foldersToScan[0] == stdinPath {
+			log.Info("Watch mode only scans paths, -git and -path - are not supported.")
+			return
+		}
+		log.Info("Watching for changes. Press Ctrl+C to stop.")
+		scanner.WatchWithCallback(ctx, cliOptions.foldersToScan, cliOptions.workerCount, mres.WatchOptions{Debounce: cliOptions.watchDebounce}, onFileMatchResult, onContentMatchResult, onError)
+	case cliOptions.scanGit:
+		scanner.ScanGitWithCallback(ctx, cliOptions.foldersToScan[0], cliOptions.gitOptions, cliOptions.workerCount, onFileMatchResult, onContentMatchResult, onError)
+	case len(cliOptions.foldersToScan) == 1 && cliOptions.foldersToScan[0] == stdinPath:
+		results, err := scanner.ScanReader(ctx, cliOptions.stdinName, os.Stdin)
+		for _, r := range results {
+			onContentMatchResult(r)
+		}
+		if err != nil {
+			onError(err)
+		}
+	default:
+		scanner.ScanWithCallback(ctx, cliOptions.foldersToScan, cliOptions.workerCount, onFileMatchResult, onContentMatchResult, onError)
+	}
 	timeTaken := time.Now().Sub(start)
+	if cache != nil {
+		if err := cache.Save(); err != nil {
+			log.Error(err, "Cannot save the cache file")
+		}
+	}
 	log.Info(fmt.Sprintf("Timetaken: %s", timeTaken))
 	log.Info(fmt.Sprintf("Total results: %d", fmResultsCount+cmResultsCount))
 	log.Info(fmt.Sprintf("Total errors: %d", errorsCount))
+	if skippedCount > 0 || abortedCount > 0 {
+		log.Info(fmt.Sprintf("Files skipped for -max-file-size: %d, aborted for -file-timeout: %d", skippedCount, abortedCount))
+	}
 	if cliOptions.outputToFile {
+		if err := writeJSON(cliOptions.outputFilePath, results); err != nil {
+			log.Error(err, "Cannot write the output file")
+			return
+		}
 		log.Info(fmt.Sprintf("Output written to file: %s", cliOptions.outputFilePath))
 	}
 }
 
-func parseCliOptions() (*cliOptions, error) {
+// writeJSON writes the value as indented JSON to the file at path
+func writeJSON(path string, value interface{}) error {
+	content, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(content, '\n'), 0644)
+}
+
+// resolvedInfo prefixes the results which are resolved in watch mode
+func resolvedInfo(resolved bool) string {
+	if resolved {
+		return "Resolved: "
+	}
+	return ""
+}
+
+func linkInfo(linkPath string) string {
+	if linkPath == "" {
+		return ""
+	}
+	return fmt.Sprintf(", link: %s", linkPath)
+}
+
+func ruleInfo(rule *mres.RuleMetadata) string {
+	if rule == nil {
+		return ""
+	}
+	info := ""
+	if rule.Name != "" {
+		info += fmt.Sprintf(", name: %s", rule.Name)
+	}
+	if rule.Severity != "" {
+		info += fmt.Sprintf(", severity: %s", rule.Severity)
+	}
+	return info
+}
+
+// ruleLabel is the rule id with its severity, if any, for the grep like outputs
+func ruleLabel(expID string, rule *mres.RuleMetadata) string {
+	if rule == nil || rule.Severity == "" {
+		return expID
+	}
+	return fmt.Sprintf("%s %s", expID, rule.Severity)
+}
+
+func validationInfo(status mres.ValidationStatus) string {
+	if status == "" {
+		return ""
+	}
+	return fmt.Sprintf(", validation: %s", status)
+}
+
+// encodingInfo notes the matches of content transcoded to UTF-8
+func encodingInfo(encoding mres.Encoding) string {
+	if encoding == "" {
+		return ""
+	}
+	return fmt.Sprintf(", encoding: %s", encoding)
+}
+
+// keyPathInfo is the key path of the values matched by structured rules
+func keyPathInfo(keyPath string) string {
+	if keyPath == "" {
+		return ""
+	}
+	return fmt.Sprintf(", key: %s", keyPath)
+}
+
+// truncatedInfo notes the matches which reached a match limit
+func truncatedInfo(truncated bool) string {
+	if !truncated {
+		return ""
+	}
+	return ", truncated: match limit reached"
+}
+
+func commitInfo(commit *mres.CommitInfo) string {
+	if commit == nil {
+		return ""
+	}
+	return fmt.Sprintf(", commit: %s, author: %s, date: %s", commit.SHA, commit.Author, commit.Date.Format(time.RFC3339))
+}
+
+func parseCliOptions(args []string) (*cliOptions, error) {
 	// flags
-	//configPathPtr := flag.String("config", "", "Relative or absolute path to the config file")
-	pathPtr := flag.String("path", "", "Relative or absolute path of the folder or a file to scan")
+	configPathPtr := flag.String("config", "", "Relative or absolute path to the JSON config file with the expressions")
+	profilePtr := flag.String("profile", "", "Profile of the config file applied on top of its rules, e.g. for an environment.")
+	pathPtr := flag.String("path", "", "Relative or absolute path of the folder or a file to scan. Use - to scan the content piped to stdin.")
 	fileRegexStrPtr := flag.String("file", "", "This is a regex supported flag which can be used to filter files with specific extensions or in specific subpath relative to the given path")
 	contentRegexStrPtr := flag.String("content", "", "Regular Expression")
 	resultDumpPathPtr := flag.String("out", "", "Relative or absolute path to the dump the results. The results will be written in JSON format. If a value is not specified, the results will be written to Stdout.")
 	workerCountPtr := flag.Int("workers", 2, "Number of workers. Increase it if you are scanning through large number of files and complex regular expressions.")
-	flag.Parse()
+	followSymlinksPtr := flag.Bool("follow-symlinks", false, "Follow symbolic links. Link cycles and targets which are already scanned are skipped.")
+	maxDepthPtr := flag.Int("max-depth", 0, "Maximum depth to walk below the path. Files directly inside the path are at depth 1. 0 means no limit.")
+	xdevPtr := flag.Bool("xdev", false, "Stay on the filesystem of the path, directories on other filesystems are skipped.")
+	skipHiddenPtr := flag.Bool("skip-hidden", false, "Skip files and directories whose name starts with a dot.")
+	skipSpecialPtr := flag.Bool("skip-special", false, "Skip devices, named pipes, sockets and other special files.")
+	archivesPtr := flag.Bool("archives", false, "Scan the entries of zip, jar, war, ear, apk, tar, tar.gz and tar.bz2 archives. Entries are reported as <archive>!/<entry>.")
+	archiveDepthPtr := flag.Int("archive-depth", 0, "Maximum nesting of archives in archives. Defaults to 3.")
+	archiveEntrySizePtr := flag.Int64("archive-entry-size", 0, "Maximum uncompressed size of an archive entry in bytes. Defaults to 100MB.")
+	archiveTotalSizePtr := flag.Int64("archive-total-size", 0, "Maximum uncompressed size of all the entries of an archive in bytes. Defaults to 1GB.")
+	decompressPtr := flag.Bool("decompress", false, "Decompress gzip, bzip2, zstd and xz compressed files before matching the content.")
+	decompressSizePtr := flag.Int64("decompress-size", 0, "Maximum decompressed size of a file in bytes. Defaults to 1GB.")
+	stdinNamePtr := flag.String("stdin-name", "-", "File path reported for the content scanned from stdin. The -file expression is matched against it.")
+	gitPtr := flag.Bool("git", false, "Scan the history of the git repository at -path instead of its working tree. Every blob reachable from -git-refs is scanned once.")
+	gitRefsPtr := flag.String("git-refs", "", "Comma separated refs whose history is scanned with -git. Defaults to all refs.")
+	gitRangePtr := flag.String("git-range", "", "Scan only the lines added by the commits in the range with -git, e.g. main..feature.")
+	cachePathPtr := flag.String("cache", "", "Path of the cache file. Unchanged files are not scanned again and their cached results are reported. The cache is reset when the expressions change.")
+	walkerCountPtr := flag.Int("walkers", 0, "Number of goroutines reading directories. Increase it for network filesystems. If not set, the number of workers is used.")
+	tagsPtr := flag.String("tags", "", "Comma separated tags, only the rules of the config with one of the tags are used.")
+	minSeverityPtr := flag.String("min-severity", "", "Only use the rules of the config with at least this severity: info, low, medium, high or critical.")
+	redactPtr := flag.String("redact", "", "Redact the matches of the rules without a redaction policy before they are reported. Use mask or hash.")
+	maxFileMatchesPtr := flag.Int("max-file-matches", 0, "Maximum number of content matches of a file, the rest of the file is not scanned. 0 means no limit.")
+	maxRuleFileMatchesPtr := flag.Int("max-rule-file-matches", 0, "Maximum number of content matches of a rule in a file. 0 means no limit.")
+	maxRuleMatchesPtr := flag.Int("max-rule-matches", 0, "Maximum number of content matches of a rule in the scan. 0 means no limit.")
+	firstMatchPtr := flag.Bool("first-match", false, "Stop scanning a file at its first content match, like grep -l.")
+	maxFileSizePtr := flag.Int64("max-file-size", 0, "Maximum size in bytes of a file whose content is scanned, larger files are reported and skipped. 0 means no limit.")
+	fileTimeoutPtr := flag.Duration("file-timeout", 0, "Maximum time spent scanning a file, e.g. 30s. The scan of the file is reported and aborted after it. 0 means no limit.")
+	watchDebouncePtr := flag.Duration("watch-debounce", 0, "How long the changes have to settle before the changed files are scanned again in watch mode. Defaults to 500ms.")
+	flag.CommandLine.Parse(args)
 	if *pathPtr == "" {
 		log.Info("Invalid path value specified. Check help by using -help option.")
 		return nil, errInvalidCliOptions
 	}
-	fileFilterEnabled := false
-	if *fileRegexStrPtr != "" {
-		fileFilterEnabled = true
-	}
-	contentFilterEnabled := false
-	if *contentRegexStrPtr != "" {
-		contentFilterEnabled = true
+	mresExp, err := buildExpressions(*configPathPtr, *profilePtr, *fileRegexStrPtr, *contentRegexStrPtr, splitList(*tagsPtr), *minSeverityPtr)
+	if err != nil {
+		return nil, err
 	}
-	if !fileFilterEnabled && !contentFilterEnabled {
-		log.Info("Specify either -filefilter or -regex or both. Check help by using -help option.")
-		return nil, errInvalidCliOptions
+	for i := range mresExp.ContentMatchExps {
+		if mresExp.ContentMatchExps[i].Redact.Mode == mres.RedactNone {
+			mresExp.ContentMatchExps[i].Redact.Mode = mres.RedactMode(*redactPtr)
+		}
 	}
 	workerCount := *workerCountPtr
 	if workerCount < 1 {
 		workerCount = 1
 	}
-	mresExp := mres.Expressions{}
-	switch {
-	case contentFilterEnabled:
-		mresExp.ContentMatchExps = []mres.ContentMatchExp{
-			{
-				ID:                "cli",
-				Exp:               *contentRegexStrPtr,
-				FileFilterEnabled: fileFilterEnabled,
-				FileMatchExp: mres.FileMatchExp{
-					Exp: *fileRegexStrPtr,
-				},
-			},
-		}
-	case fileFilterEnabled && !contentFilterEnabled:
-		mresExp.FileMatchExps = []mres.FileMatchExp{
-			{
-				ID:  "cli",
-				Exp: *fileRegexStrPtr,
-			},
-		}
-
-	}
 	cliOptions := &cliOptions{
 		mresExpressions: mresExp,
 		foldersToScan:   []string{*pathPtr},
 		workerCount:     workerCount,
 		outputToFile:    *resultDumpPathPtr != "",
 		outputFilePath:  *resultDumpPathPtr,
+		walkOptions: mres.WalkOptions{
+			FollowSymlinks:   *followSymlinksPtr,
+			MaxDepth:         *maxDepthPtr,
+			OneFileSystem:    *xdevPtr,
+			SkipHidden:       *skipHiddenPtr,
+			SkipSpecialFiles: *skipSpecialPtr,
+			Workers:          *walkerCountPtr,
+		},
+		archiveOptions: mres.ArchiveOptions{
+			Enabled:      *archivesPtr,
+			MaxDepth:     *archiveDepthPtr,
+			MaxEntrySize: *archiveEntrySizePtr,
+			MaxTotalSize: *archiveTotalSizePtr,
+		},
+		decompressOpts: mres.DecompressOptions{
+			Enabled: *decompressPtr,
+			MaxSize: *decompressSizePtr,
+		},
+		matchLimits: mres.MatchLimits{
+			MaxPerFile:        *maxFileMatchesPtr,
+			MaxPerRulePerFile: *maxRuleFileMatchesPtr,
+			MaxPerRule:        *maxRuleMatchesPtr,
+			FirstMatchOnly:    *firstMatchPtr,
+		},
+		fileLimits: mres.FileLimits{
+			MaxSize: *maxFileSizePtr,
+			Timeout: *fileTimeoutPtr,
+		},
+		stdinName: *stdinNamePtr,
+		scanGit:   *gitPtr,
+		gitOptions: mres.GitScanOptions{
+			Refs:      splitList(*gitRefsPtr),
+			DiffRange: *gitRangePtr,
+		},
+		cachePath:     *cachePathPtr,
+		watchDebounce: *watchDebouncePtr,
 	}
 	return cliOptions, nil
 }
+
+// splitList splits a comma separated flag value
+func splitList(value string) []string {
+	list := make([]string, 0)
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// buildExpressions loads the expressions of the config file with the profile, if any, and adds the ones of the -file and -content flags
+// The rules of the config file are filtered by the tags and the minimum severity.
+func buildExpressions(configPath string, profile string, fileExp string, contentExp string, tags []string, minSeverity string) (mres.Expressions, error) {
+	mresExp := mres.Expressions{}
+	switch mres.Severity(minSeverity) {
+	case "", mres.SeverityInfo, mres.SeverityLow, mres.SeverityMedium, mres.SeverityHigh, mres.SeverityCritical:
+	default:
+		log.Info(fmt.Sprintf("Invalid -min-severity value: %s. Use info, low, medium, high or critical.", minSeverity))
+		return mresExp, errInvalidCliOptions
+	}
+	if configPath != "" {
+		exps, err := mres.LoadProfileExpressions(configPath, profile)
+		if err != nil {
+			log.Error(err, "Cannot load the config file")
+			return mresExp, errInvalidCliOptions
+		}
+		mresExp = exps
+		if len(tags) > 0 || minSeverity != "" {
+			mresExp = exps.Filter(tags, mres.Severity(minSeverity))
+		}
+	}
+	fileFilterEnabled := fileExp != ""
+	contentFilterEnabled := contentExp != ""
+	if !fileFilterEnabled && !contentFilterEnabled && configPath == "" {
+		log.Info("Specify either -config or -file or -content. Check help by using -help option.")
+		return mresExp, errInvalidCliOptions
+	}
+	switch {
+	case contentFilterEnabled:
+		mresExp.ContentMatchExps = append(mresExp.ContentMatchExps, mres.ContentMatchExp{
+			ID:                "cli",
+			Exp:               contentExp,
+			FileFilterEnabled: fileFilterEnabled,
+			FileMatchExp: mres.FileMatchExp{
+				Exp: fileExp,
+			},
+		})
+	case fileFilterEnabled:
+		mresExp.FileMatchExps = append(mresExp.FileMatchExps, mres.FileMatchExp{
+			ID:  "cli",
+			Exp: fileExp,
+		})
+	}
+	return mresExp, nil
+}
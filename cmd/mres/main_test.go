@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/movna/mres"
+)
+
+func TestBuildExpressionsForcedEncoding(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "rules.json")
+	config := `{
+  "content_match_exps": [{"id": "xorp", "exp": "xorp-\\d+", "severity": "high", "tags": ["tokens"]}],
+  "file_filters": [{"id": "legacy-ini", "exp": "\\.ini$", "encoding": "utf-16le"}]
+}`
+	if err := os.WriteFile(configPath, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// no BOM and mostly non ASCII text, the encoding is not detected
+	content := make([]byte, 0)
+	for _, unit := range utf16.Encode([]rune(strings.Repeat("ключ", 5) + "=xorp-1")) {
+		content = append(content, byte(unit), byte(unit>>8))
+	}
+	tests := []struct {
+		name        string
+		tags        []string
+		minSeverity string
+	}{
+		{name: "no filter"},
+		{name: "tags and min severity", tags: []string{"tokens"}, minSeverity: "high"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exps, err := buildExpressions(configPath, "", "", "", tt.tags, tt.minSeverity)
+			if err != nil {
+				t.Fatalf("buildExpressions() error = %v", err)
+			}
+			scanner, errs := mres.NewScanner(exps)
+			if len(errs) > 0 {
+				t.Fatalf("NewScanner() errs = %v", errs)
+			}
+			results, err := scanner.ScanReader(context.TODO(), "legacy.ini", strings.NewReader(string(content)))
+			if err != nil || len(results) != 1 || results[0].Encoding != mres.EncodingUTF16LE {
+				t.Errorf("Scanner.ScanReader() = %v, %v, want a match with encoding %s", results, err, mres.EncodingUTF16LE)
+			}
+		})
+	}
+}
+
+// captureStdout returns what run prints to the standard output and its exit code
+func captureStdout(t *testing.T, run func() int) (string, int) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+	outC := make(chan string)
+	go func() {
+		out, _ := io.ReadAll(r)
+		outC <- string(out)
+	}()
+	code := run()
+	w.Close()
+	return <-outC, code
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/movna/mres"
+)
+
+const (
+	precommitExitClean    = 0
+	precommitExitFindings = 1
+	precommitExitError    = 2
+
+	// precommitHookMarker identifies hooks written by mres precommit install
+	precommitHookMarker = "# installed by mres precommit install"
+)
+
+// runPrecommit scans the staged content of the changed files of a repository and returns the exit code,
+// which is non-zero when there are findings so the commit is blocked
+func runPrecommit(args []string) int {
+	if len(args) > 0 && args[0] == "install" {
+		return runPrecommitInstall(args[1:])
+	}
+	flags := flag.NewFlagSet("precommit", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: mres precommit [options]\n       mres precommit install [options]\n\nScans the staged content of the changed files and exits with 1 if there are findings.")
+		flags.PrintDefaults()
+	}
+	repoPtr := flags.String("repo", ".", "Path of the git repository")
+	configPathPtr := flags.String("config", "", "Relative or absolute path to the JSON config file with the expressions")
+	profilePtr := flags.String("profile", "", "Profile of the config file applied on top of its rules")
+	fileRegexStrPtr := flags.String("file", "", "Regex to filter the files to scan or to list")
+	contentRegexStrPtr := flags.String("content", "", "Regular Expression")
+	tagsPtr := flags.String("tags", "", "Comma separated tags, only the rules of the config with one of the tags are used")
+	minSeverityPtr := flags.String("min-severity", "", "Only use the rules of the config with at least this severity")
+	workerCountPtr := flags.Int("workers", 2, "Number of workers")
+	if err := flags.Parse(args); err != nil {
+		return precommitExitError
+	}
+	exps, err := buildExpressions(*configPathPtr, *profilePtr, *fileRegexStrPtr, *contentRegexStrPtr, splitList(*tagsPtr), *minSeverityPtr)
+	if err != nil {
+		return precommitExitError
+	}
+	scanner, errs := mres.NewScanner(exps)
+	if len(errs) > 0 {
+		for _, e := range errs {
+			fmt.Fprintln(os.Stderr, e)
+		}
+		return precommitExitError
+	}
+	findings := 0
+	errorsCount := 0
+	onFileMatchResult := func(r mres.FileMatchResult) {
+		findings++
+		fmt.Printf("%s: [%s]\n", r.FilePath, ruleLabel(r.ExpID, r.Rule))
+	}
+	onContentMatchResult := func(r mres.ContentMatchResult) {
+		findings++
+		fmt.Printf("%s:%d: [%s] %s\n", r.FilePath, r.LineNumber, ruleLabel(r.ExpID, r.Rule), r.MatchString)
+	}
+	onError := func(e error) {
+		errorsCount++
+		fmt.Fprintln(os.Stderr, e)
+	}
+	opts := mres.GitScanOptions{Staged: true}
+	scanner.ScanGitWithCallback(context.Background(), *repoPtr, opts, *workerCountPtr, onFileMatchResult, onContentMatchResult, onError)
+	switch {
+	case errorsCount > 0:
+		return precommitExitError
+	case findings > 0:
+		fmt.Fprintf(os.Stderr, "mres: %d finding(s) in the staged changes, commit blocked\n", findings)
+		return precommitExitFindings
+	}
+	return precommitExitClean
+}
+
+// runPrecommitInstall writes the pre-commit hook of a repository running mres precommit
+func runPrecommitInstall(args []string) int {
+	flags := flag.NewFlagSet("precommit install", flag.ContinueOnError)
+	repoPtr := flags.String("repo", ".", "Path of the git repository")
+	configPathPtr := flags.String("config", "", "Relative or absolute path to the JSON config file used by the hook")
+	forcePtr := flags.Bool("force", false, "Overwrite an existing pre-commit hook which was not installed by mres")
+	if err := flags.Parse(args); err != nil {
+		return precommitExitError
+	}
+	if *configPathPtr == "" {
+		fmt.Fprintln(os.Stderr, "Specify the -config used by the hook. Check help by using -help option.")
+		return precommitExitError
+	}
+	configPath, err := filepath.Abs(*configPathPtr)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return precommitExitError
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return precommitExitError
+	}
+	// hooks can live outside .git with core.hooksPath or in worktrees
+	cmd := exec.Command("git", "-C", *repoPtr, "rev-parse", "--git-path", "hooks")
+	out, err := cmd.Output()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v while finding the hooks directory of: %s\n", err, *repoPtr)
+		return precommitExitError
+	}
+	hooksDir := strings.TrimSpace(string(out))
+	if !filepath.IsAbs(hooksDir) {
+		hooksDir = filepath.Join(*repoPtr, hooksDir)
+	}
+	hookPath := filepath.Join(hooksDir, "pre-commit")
+	if existing, err := os.ReadFile(hookPath); err == nil && !bytes.Contains(existing, []byte(precommitHookMarker)) && !*forcePtr {
+		fmt.Fprintf(os.Stderr, "A pre-commit hook already exists at: %s, use -force to overwrite it\n", hookPath)
+		return precommitExitError
+	}
+	hook := fmt.Sprintf("#!/bin/sh\n%s\nexec %s precommit -config %s\n", precommitHookMarker, shellQuote(executable), shellQuote(configPath))
+	if err := os.MkdirAll(hooksDir, 0755); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return precommitExitError
+	}
+	if err := os.WriteFile(hookPath, []byte(hook), 0755); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return precommitExitError
+	}
+	// the mode is not changed when an existing hook is overwritten
+	if err := os.Chmod(hookPath, 0755); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return precommitExitError
+	}
+	fmt.Printf("Installed pre-commit hook: %s\n", hookPath)
+	return precommitExitClean
+}
+
+// shellQuote quotes a value for a POSIX shell
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestRepo creates an empty git repository and a config with a rule matching xorp tokens
+func newTestRepo(t *testing.T) (string, string, func(args ...string)) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	repo := t.TempDir()
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-C", repo, "-c", "user.name=Tester", "-c", "user.email=tester@example.com"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("error while running git %v. err: %v, out: %s", args, err, out)
+		}
+	}
+	git("init", "-q")
+	configPath := filepath.Join(t.TempDir(), "my 'rules'", "rules.json")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(configPath, []byte(`{"content_match_exps": [{"id": "xorp", "exp": "xorp-\\d+"}]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return repo, configPath, git
+}
+
+func TestRunPrecommitInstall(t *testing.T) {
+	repo, configPath, git := newTestRepo(t)
+	hookPath := filepath.Join(repo, ".git", "hooks", "pre-commit")
+	install := func(extra ...string) int {
+		return runPrecommit(append([]string{"install", "-repo", repo, "-config", configPath}, extra...))
+	}
+	if code := install(); code != precommitExitClean {
+		t.Fatalf("runPrecommit(install) = %v, want %v", code, precommitExitClean)
+	}
+	hook, err := os.ReadFile(hookPath)
+	if err != nil {
+		t.Fatalf("error while reading the hook. err: %v", err)
+	}
+	if !strings.Contains(string(hook), precommitHookMarker) || !strings.Contains(string(hook), "precommit -config "+shellQuote(configPath)) {
+		t.Errorf("runPrecommit(install) hook = %s, want the marker and the quoted config path", hook)
+	}
+	if fi, err := os.Stat(hookPath); err != nil || fi.Mode().Perm()&0100 == 0 {
+		t.Errorf("runPrecommit(install) hook mode = %v, %v, want an executable hook", fi.Mode(), err)
+	}
+	// a hook installed by mres is replaced, another one only with -force
+	if code := install(); code != precommitExitClean {
+		t.Errorf("runPrecommit(install) over its own hook = %v, want %v", code, precommitExitClean)
+	}
+	custom := "#!/bin/sh\necho custom\n"
+	if err := os.WriteFile(hookPath, []byte(custom), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if code := install(); code != precommitExitError {
+		t.Errorf("runPrecommit(install) over another hook = %v, want %v", code, precommitExitError)
+	}
+	if hook, _ := os.ReadFile(hookPath); string(hook) != custom {
+		t.Errorf("runPrecommit(install) overwrote the hook without -force: %s", hook)
+	}
+	if code := install("-force"); code != precommitExitClean {
+		t.Errorf("runPrecommit(install -force) = %v, want %v", code, precommitExitClean)
+	}
+	if fi, err := os.Stat(hookPath); err != nil || fi.Mode().Perm()&0100 == 0 {
+		t.Errorf("runPrecommit(install -force) hook mode = %v, %v, want an executable hook", fi.Mode(), err)
+	}
+	// the hooks directory of core.hooksPath is created
+	git("config", "core.hooksPath", "custom-hooks")
+	if code := install(); code != precommitExitClean {
+		t.Errorf("runPrecommit(install) with core.hooksPath = %v, want %v", code, precommitExitClean)
+	}
+	if _, err := os.Stat(filepath.Join(repo, "custom-hooks", "pre-commit")); err != nil {
+		t.Errorf("runPrecommit(install) with core.hooksPath error = %v, want the hook in custom-hooks", err)
+	}
+	if code := runPrecommit([]string{"install", "-repo", repo}); code != precommitExitError {
+		t.Errorf("runPrecommit(install) without -config = %v, want %v", code, precommitExitError)
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not installed")
+	}
+	for _, value := range []string{"plain", "with space", "it's", `$HOME "x" \n; rm`} {
+		out, err := exec.Command("sh", "-c", "printf %s "+shellQuote(value)).Output()
+		if err != nil || string(out) != value {
+			t.Errorf("shellQuote(%q) read by sh = %q, %v, want %q", value, out, err, value)
+		}
+	}
+}
+
+func TestRunPrecommit(t *testing.T) {
+	repo, configPath, git := newTestRepo(t)
+	write := func(name string, content string) {
+		if err := os.WriteFile(filepath.Join(repo, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	precommit := func(configPath string) int {
+		return runPrecommit([]string{"-repo", repo, "-config", configPath})
+	}
+	write("app.env", "TOKEN=\n")
+	git("add", "app.env")
+	if code := precommit(configPath); code != precommitExitClean {
+		t.Errorf("runPrecommit() without findings = %v, want %v", code, precommitExitClean)
+	}
+	// only the staged content is scanned
+	write("notes.txt", "xorp-1\n")
+	if code := precommit(configPath); code != precommitExitClean {
+		t.Errorf("runPrecommit() with an unstaged secret = %v, want %v", code, precommitExitClean)
+	}
+	write("app.env", "TOKEN=xorp-2\n")
+	git("add", "app.env")
+	if code := precommit(configPath); code != precommitExitFindings {
+		t.Errorf("runPrecommit() with a staged secret = %v, want %v", code, precommitExitFindings)
+	}
+	if code := precommit(filepath.Join(repo, "missing.json")); code != precommitExitError {
+		t.Errorf("runPrecommit() with a missing config = %v, want %v", code, precommitExitError)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/movna/mres"
+)
+
+const (
+	testRulesExitPass  = 0
+	testRulesExitFail  = 1
+	testRulesExitError = 2
+)
+
+// runTestRules tests the rules of a config with their examples and returns the exit code,
+// which is non-zero when an example fails
+func runTestRules(args []string) int {
+	flags := flag.NewFlagSet("test-rules", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: mres test-rules -config <rules.json>\n\nLints the rules of the config and tests every rule with its positive and negative examples.")
+		flags.PrintDefaults()
+	}
+	configPathPtr := flags.String("config", "", "Relative or absolute path to the JSON config file with the expressions")
+	profilePtr := flags.String("profile", "", "Profile of the config file applied on top of its rules")
+	if err := flags.Parse(args); err != nil {
+		return testRulesExitError
+	}
+	if *configPathPtr == "" {
+		fmt.Fprintln(os.Stderr, "Specify the -config to test. Check help by using -help option.")
+		return testRulesExitError
+	}
+	exps, err := mres.LoadProfileExpressions(*configPathPtr, *profilePtr)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return testRulesExitError
+	}
+	// the lines of the rules are only known for the config file itself
+	issues := mres.LintExpressions(exps)
+	if *profilePtr == "" {
+		issues, err = mres.LintConfig(*configPathPtr)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return testRulesExitError
+		}
+	}
+	lintErrors := 0
+	for _, issue := range issues {
+		fmt.Fprintln(os.Stderr, issue)
+		if issue.Level == mres.LintError {
+			lintErrors++
+		}
+	}
+	if lintErrors > 0 {
+		return testRulesExitError
+	}
+	failures := make(map[string][]*mres.RuleTestError)
+	for _, err := range mres.ValidateExpressions(exps) {
+		var testErr *mres.RuleTestError
+		if !errors.As(err, &testErr) {
+			fmt.Fprintln(os.Stderr, err)
+			return testRulesExitError
+		}
+		failures[testErr.ExpID] = append(failures[testErr.ExpID], testErr)
+	}
+	examples := 0
+	report := func(id string, e mres.RuleExamples) {
+		examples += len(e.Positive) + len(e.Negative)
+		if len(failures[id]) == 0 {
+			fmt.Printf("ok   %s\n", id)
+			return
+		}
+		fmt.Printf("FAIL %s\n", id)
+		for _, f := range failures[id] {
+			fmt.Printf("     %s\n", f)
+		}
+	}
+	failed := 0
+	for _, list := range failures {
+		failed += len(list)
+	}
+	for _, e := range exps.FileMatchExps {
+		report(e.ID, e.Examples)
+	}
+	for _, e := range exps.ContentMatchExps {
+		report(e.ID, e.Examples)
+	}
+	fmt.Printf("rules: %d, examples: %d, failures: %d\n", len(exps.FileMatchExps)+len(exps.ContentMatchExps), examples, failed)
+	if failed > 0 {
+		return testRulesExitFail
+	}
+	return testRulesExitPass
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunTestRules(t *testing.T) {
+	dir := t.TempDir()
+	writeConfig := func(name string, config string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	tests := []struct {
+		name     string
+		config   string
+		wantCode int
+		wantOut  string
+	}{
+		{
+			name:     "passing examples",
+			config:   `{"content_match_exps": [{"id": "xorp", "exp": "xorp-\\d+", "examples": {"positive": ["token: xorp-1"], "negative": ["xorp-"]}}]}`,
+			wantCode: testRulesExitPass,
+			wantOut:  "ok   xorp\nrules: 1, examples: 2, failures: 0\n",
+		},
+		{
+			name:     "failing example",
+			config:   `{"content_match_exps": [{"id": "xorp", "exp": "xorp-\\d+", "examples": {"positive": ["token: xorp-1", "token: xorp-a"]}}]}`,
+			wantCode: testRulesExitFail,
+			wantOut:  "FAIL xorp\n     rule: xorp did not match positive example: \"token: xorp-a\"\nrules: 1, examples: 2, failures: 1\n",
+		},
+		{
+			name:     "invalid rule",
+			config:   `{"content_match_exps": [{"id": "xorp", "exp": "xorp-(", "examples": {"positive": ["xorp-1"]}}]}`,
+			wantCode: testRulesExitError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configPath := writeConfig(tt.name+".json", tt.config)
+			out, code := captureStdout(t, func() int { return runTestRules([]string{"-config", configPath}) })
+			if code != tt.wantCode {
+				t.Errorf("runTestRules() = %v, want %v", code, tt.wantCode)
+			}
+			if out != tt.wantOut {
+				t.Errorf("runTestRules() output = %q, want %q", out, tt.wantOut)
+			}
+		})
+	}
+	if code := runTestRules(nil); code != testRulesExitError {
+		t.Errorf("runTestRules() without -config = %v, want %v", code, testRulesExitError)
+	}
+}
@@ -0,0 +1,200 @@
+package mres
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type (
+	// configLayer is a set of rules applied on top of the inherited ones
+	configLayer struct {
+		// Include are the config files, or directories of *.json config files, whose rules are inherited.
+		// Relative paths are relative to the directory of the config file.
+		Include []string `json:"include,omitempty"`
+		// Disable are the ids of the inherited rules which are dropped
+		Disable []string `json:"disable,omitempty"`
+		// the rules override the inherited rules with the same id
+		Expressions
+	}
+
+	// configFile is the JSON config file
+	configFile struct {
+		configLayer
+		// Profiles are applied on top of the config when they are selected, e.g. for an environment
+		Profiles map[string]configLayer `json:"profiles,omitempty"`
+	}
+
+	// configLoader resolves the includes and the profile of config files
+	configLoader struct {
+		profile      string
+		profileFound bool
+		// loading are the config files being loaded, to detect include cycles
+		loading map[string]bool
+	}
+)
+
+// LoadExpressions reads the expressions from a JSON config file and the config files it includes
+func LoadExpressions(path string) (Expressions, error) {
+	return LoadProfileExpressions(path, "")
+}
+
+// LoadProfileExpressions reads the expressions from a JSON config file and the config files it includes, and
+// applies the profile of the config files on top of them. The profile has to be defined by at least one of them.
+func LoadProfileExpressions(path string, profile string) (Expressions, error) {
+	loader := &configLoader{profile: profile, loading: make(map[string]bool)}
+	exps, err := loader.load(path)
+	if err != nil {
+		return Expressions{}, err
+	}
+	if profile != "" && !loader.profileFound {
+		return Expressions{}, fmt.Errorf("%w: unknown profile: %s in config: %s", ErrInvalidArgument, profile, path)
+	}
+	return exps, nil
+}
+
+func (l *configLoader) load(path string) (Expressions, error) {
+	exps := Expressions{}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return exps, err
+	}
+	if l.loading[absPath] {
+		return exps, fmt.Errorf("%w: include cycle at config: %s", ErrInvalidArgument, path)
+	}
+	l.loading[absPath] = true
+	defer delete(l.loading, absPath)
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return exps, err
+	}
+	config := configFile{}
+	if err := json.Unmarshal(content, &config); err != nil {
+		return exps, fmt.Errorf("error: %w while parsing config: %s", err, path)
+	}
+	exps, err = l.apply(exps, path, config.configLayer)
+	if err != nil {
+		return exps, err
+	}
+	if layer, ok := config.Profiles[l.profile]; ok && l.profile != "" {
+		l.profileFound = true
+		return l.apply(exps, path, layer)
+	}
+	return exps, nil
+}
+
+// apply returns the rules of the layer of the config file at path on top of the inherited ones
+func (l *configLoader) apply(inherited Expressions, path string, layer configLayer) (Expressions, error) {
+	for _, include := range layer.Include {
+		if !filepath.IsAbs(include) {
+			include = filepath.Join(filepath.Dir(path), include)
+		}
+		files, err := configFiles(include)
+		if err != nil {
+			return inherited, fmt.Errorf("error: %w while including: %s in config: %s", err, include, path)
+		}
+		for _, file := range files {
+			exps, err := l.load(file)
+			if err != nil {
+				return inherited, err
+			}
+			inherited = mergeExpressions(inherited, exps)
+		}
+	}
+	for _, id := range layer.Disable {
+		var found bool
+		if inherited, found = disableRule(inherited, id); !found {
+			return inherited, fmt.Errorf("%w: disabled rule: %s is not inherited in config: %s", ErrInvalidArgument, id, path)
+		}
+	}
+	return mergeExpressions(inherited, layer.Expressions), nil
+}
+
+// configFiles returns the config file at path, or the *.json config files of the directory at path sorted by name
+func configFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".json") {
+			files = append(files, filepath.Join(path, entry.Name()))
+		}
+	}
+	return files, nil
+}
+
+// mergeExpressions returns the rules of base overridden in place by the rules of over with the same id,
+// followed by the other rules of over
+func mergeExpressions(base Expressions, over Expressions) Expressions {
+	merged := Expressions{
+		FileMatchExps:    append([]FileMatchExp(nil), base.FileMatchExps...),
+		ContentMatchExps: append([]ContentMatchExp(nil), base.ContentMatchExps...),
+		FileFilters:      append([]FileMatchExp(nil), base.FileFilters...),
+	}
+	fileIndex := make(map[string]int)
+	for i, e := range base.FileMatchExps {
+		fileIndex[e.ID] = i
+	}
+	for _, e := range over.FileMatchExps {
+		if i, ok := fileIndex[e.ID]; ok {
+			merged.FileMatchExps[i] = e
+			continue
+		}
+		merged.FileMatchExps = append(merged.FileMatchExps, e)
+	}
+	contentIndex := make(map[string]int)
+	for i, e := range base.ContentMatchExps {
+		contentIndex[e.ID] = i
+	}
+	for _, e := range over.ContentMatchExps {
+		if i, ok := contentIndex[e.ID]; ok {
+			merged.ContentMatchExps[i] = e
+			continue
+		}
+		merged.ContentMatchExps = append(merged.ContentMatchExps, e)
+	}
+	filterIndex := make(map[string]int)
+	for i, e := range base.FileFilters {
+		filterIndex[e.ID] = i
+	}
+	for _, e := range over.FileFilters {
+		if i, ok := filterIndex[e.ID]; ok {
+			merged.FileFilters[i] = e
+			continue
+		}
+		merged.FileFilters = append(merged.FileFilters, e)
+	}
+	return merged
+}
+
+// disableRule drops the file and content rules with the id, it reports if there was one
+func disableRule(exps Expressions, id string) (Expressions, bool) {
+	found := false
+	kept := Expressions{FileFilters: exps.FileFilters}
+	for _, e := range exps.FileMatchExps {
+		if e.ID == id {
+			found = true
+			continue
+		}
+		kept.FileMatchExps = append(kept.FileMatchExps, e)
+	}
+	for _, e := range exps.ContentMatchExps {
+		if e.ID == id {
+			found = true
+			continue
+		}
+		kept.ContentMatchExps = append(kept.ContentMatchExps, e)
+	}
+	return kept, found
+}
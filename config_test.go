@@ -0,0 +1,94 @@
+package mres
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadExpressions(t *testing.T) {
+	root := newTestTree(t, map[string]string{
+		"rules.json":   `{"file_match_exps":[{"id":"go","exp":"\\.go$"}],"content_match_exps":[{"id":"xorp","exp":"xorp-\\w+"}]}`,
+		"invalid.json": `{"content_match_exps":`,
+	})
+	defer os.RemoveAll(root)
+	got, err := LoadExpressions(filepath.Join(root, "rules.json"))
+	if err != nil {
+		t.Fatalf("LoadExpressions() error = %v", err)
+	}
+	want := Expressions{
+		FileMatchExps:    []FileMatchExp{{ID: "go", Exp: `\.go$`}},
+		ContentMatchExps: []ContentMatchExp{{ID: "xorp", Exp: `xorp-\w+`}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("LoadExpressions() = %v, want %v", got, want)
+	}
+	for _, name := range []string{"invalid.json", "missing.json"} {
+		if _, err := LoadExpressions(filepath.Join(root, name)); err == nil {
+			t.Errorf("LoadExpressions(%s) error = nil, want error", name)
+		}
+	}
+}
+
+func TestLoadProfileExpressions(t *testing.T) {
+	root := newTestTree(t, map[string]string{
+		"base/a.json":    `{"file_match_exps":[{"id":"pem","exp":"\\.pem$"}],"content_match_exps":[{"id":"aws","exp":"AKIA\\w+"},{"id":"todo","exp":"TODO"}]}`,
+		"base/b.json":    `{"content_match_exps":[{"id":"xorp","exp":"xorp-\\w+"}]}`,
+		"base/notes.txt": `not a config`,
+		"rules.json": `{"include":["base"],"disable":["pem"],"content_match_exps":[{"id":"todo","exp":"TODO|FIXME"},{"id":"local","exp":"local"}],
+			"profiles":{"ci":{"disable":["todo"],"content_match_exps":[{"id":"aws","exp":"AKIA[0-9A-Z]{16}"}]},"prod":{"include":["prod.json"]}}}`,
+		"prod.json":        `{"content_match_exps":[{"id":"prod","exp":"prod"}]}`,
+		"cycle.json":       `{"include":["cycle2.json"]}`,
+		"cycle2.json":      `{"include":["cycle.json"]}`,
+		"bad-disable.json": `{"disable":["missing"]}`,
+		"bad-include.json": `{"include":["missing.json"]}`,
+	})
+	defer os.RemoveAll(root)
+	tests := []struct {
+		name    string
+		config  string
+		profile string
+		want    Expressions
+		wantErr bool
+	}{
+		{
+			name:   "includes, overrides and disabled rules",
+			config: "rules.json",
+			want: Expressions{ContentMatchExps: []ContentMatchExp{
+				{ID: "aws", Exp: `AKIA\w+`}, {ID: "todo", Exp: `TODO|FIXME`}, {ID: "xorp", Exp: `xorp-\w+`}, {ID: "local", Exp: `local`},
+			}},
+		},
+		{
+			name:    "profile",
+			config:  "rules.json",
+			profile: "ci",
+			want: Expressions{ContentMatchExps: []ContentMatchExp{
+				{ID: "aws", Exp: `AKIA[0-9A-Z]{16}`}, {ID: "xorp", Exp: `xorp-\w+`}, {ID: "local", Exp: `local`},
+			}},
+		},
+		{
+			name:    "profile including a config",
+			config:  "rules.json",
+			profile: "prod",
+			want: Expressions{ContentMatchExps: []ContentMatchExp{
+				{ID: "aws", Exp: `AKIA\w+`}, {ID: "todo", Exp: `TODO|FIXME`}, {ID: "xorp", Exp: `xorp-\w+`}, {ID: "local", Exp: `local`}, {ID: "prod", Exp: `prod`},
+			}},
+		},
+		{name: "unknown profile", config: "rules.json", profile: "dev", wantErr: true},
+		{name: "include cycle", config: "cycle.json", wantErr: true},
+		{name: "disabled rule not inherited", config: "bad-disable.json", wantErr: true},
+		{name: "missing include", config: "bad-include.json", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LoadProfileExpressions(filepath.Join(root, tt.config), tt.profile)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadProfileExpressions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LoadProfileExpressions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
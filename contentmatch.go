@@ -3,7 +3,7 @@ package mres
 import (
 	"bufio"
 	"fmt"
-	"os"
+	"io"
 	"regexp"
 )
 
@@ -12,7 +12,29 @@ type (
 		ID                string       `json:"id,omitempty"`
 		FileFilterEnabled bool         `json:"file_filter_enabled,omitempty"`
 		FileMatchExp      FileMatchExp `json:"file_match_exp,omitempty"`
-		Exp               string       `json:"exp,omitempty"`
+		// FileFilter is the id of a filter of Expressions.FileFilters, it cannot be combined with FileFilterEnabled
+		FileFilter string `json:"file_filter,omitempty"`
+		Exp        string `json:"exp,omitempty"`
+		// Redact redacts the matches before they are reported
+		Redact Redaction `json:"redact,omitempty"`
+		// Entropy keeps only the matches with a random looking token
+		Entropy EntropyFilter `json:"entropy,omitempty"`
+		// Validator is the name of a built-in validator deciding if the matches are kept: luhn, iban or jwt.
+		// Use Scanner.SetValidator to set a custom one.
+		Validator string `json:"validator,omitempty"`
+		// ValidatorGroup is the name or the index of the capture group the validator checks, the whole match if it is not set
+		ValidatorGroup string `json:"validator_group,omitempty"`
+		// LineSplit is how the content is split in lines: auto (LF, CRLF or CR, the default), lf, crlf, cr or nul
+		LineSplit LineSplit `json:"line_split,omitempty"`
+		// LineDelimiter splits the content in records ending with the delimiter instead of lines
+		LineDelimiter string `json:"line_delimiter,omitempty"`
+		// KeyExp makes the rule structured: it is matched with the key paths of the values of JSON, YAML, TOML,
+		// INI and .env files, e.g. $.db.password, and Exp with the values instead of the lines.
+		// Structured rules do not apply to other files.
+		KeyExp string `json:"key_exp,omitempty"`
+		// Examples are the lines the rule is tested with
+		Examples RuleExamples `json:"examples,omitempty"`
+		RuleMetadata
 		//FlipMatch since Go doesn't support negative look ahead
 		//FlipMatch bool `json:"flip_match,omitempty"`
 	}
@@ -20,64 +42,194 @@ type (
 	contentMatcher struct {
 		ID          string
 		fileMatcher *fileMatcher
-		Exp         *regexp.Regexp
+		// sharedFilter is set when fileMatcher is a named file filter, evaluated once per file
+		sharedFilter bool
+		Exp          *regexp.Regexp
+		redactor     *redactor
+		entropy      *entropyChecker
+		validator    Validator
+		// validatorGroup is the index of the capture group the validator checks, 0 for the whole match
+		validatorGroup int
+		rule           *RuleMetadata
+		// splitKey identifies split, the matchers with the same split share the lines
+		splitKey string
+		split    bufio.SplitFunc
+		// keyExp is set for structured rules
+		keyExp *regexp.Regexp
 		//FlipMatch   bool
 	}
 
 	contentMatchers []contentMatcher
 
+	// fileFilters are the compiled named file filters by id
+	fileFilters map[string]*fileMatcher
+
 	ContentMatchResult struct {
 		ExpID       string `json:"exp_id,omitempty"`
 		FilePath    string `json:"file_path,omitempty"`
 		LineNumber  int    `json:"line_number,omitempty"`
 		MatchString string `json:"match_string,omitempty"`
+		// LinkPath is the symlink path the file was reached through when following symlinks
+		LinkPath string `json:"link_path,omitempty"`
+		// Commit is the commit which introduced the content in git history scans
+		Commit *CommitInfo `json:"commit,omitempty"`
+		// Resolved is set by watch scans when a previously reported match is gone
+		Resolved bool `json:"resolved,omitempty"`
+		// Validation is set when the rule has a validator
+		Validation ValidationStatus `json:"validation,omitempty"`
+		// Rule is the metadata of the rule, if it has any
+		Rule *RuleMetadata `json:"rule,omitempty"`
+		// Encoding is the encoding the content was transcoded from, if it is not UTF-8
+		Encoding Encoding `json:"encoding,omitempty"`
+		// Truncated is set when a MatchLimits limit was reached with this match, the following ones are not reported
+		Truncated bool `json:"truncated,omitempty"`
+		// KeyPath is the key path of the value matched by a structured rule, e.g. $.db.password
+		KeyPath string `json:"key_path,omitempty"`
 	}
 )
 
-func (matchers contentMatchers) matchAll(filePath string, bufPool []byte) ([]ContentMatchResult, error) {
+// matchLines runs all the matchers line by line over the content read from r. The content is read once
+// even when the matchers split it differently. It stops early once the per file limits of limiter are reached,
+// limiter is nil if there are none.
+func (matchers contentMatchers) matchLines(name string, r io.Reader, bufPool []byte, limiter *fileMatchLimiter) ([]ContentMatchResult, error) {
 	results := make([]ContentMatchResult, 0)
-	if len(matchers) == 0 {
-		return results, nil
+	groups := matchers.splitGroups()
+	buf := bufPool[:cap(bufPool)]
+	end := 0
+	atEOF := false
+	emptyReads := 0
+	for {
+		var blocked *splitGroup
+		for _, g := range groups {
+			for !g.done {
+				line, ok, err := g.nextLine(buf[g.start:end], atEOF)
+				if err != nil {
+					return results, fmt.Errorf("error: %w while scanning: %s after line: %d", err, name, g.lineNo)
+				}
+				if !ok {
+					break
+				}
+				lineResults := g.matchers.matchLine(name, g.lineNo, line)
+				if limiter == nil {
+					results = append(results, lineResults...)
+					continue
+				}
+				kept, fileDone, ruleDone := limiter.add(lineResults)
+				results = append(results, kept...)
+				if fileDone {
+					return results, nil
+				}
+				if ruleDone {
+					g.matchers = limiter.active(g.matchers)
+					g.done = len(g.matchers) == 0
+				}
+			}
+			if !g.done && (blocked == nil || g.start < blocked.start) {
+				blocked = g
+			}
+		}
+		if blocked == nil {
+			return results, nil
+		}
+		// the content before the first unfinished line of all the groups is dropped to make room
+		if first := blocked.start; first > 0 {
+			copy(buf, buf[first:end])
+			end -= first
+			for _, g := range groups {
+				g.start -= first
+			}
+		}
+		if end == len(buf) {
+			return results, fmt.Errorf("error: %w while scanning: %s after line: %d", bufio.ErrTooLong, name, blocked.lineNo)
+		}
+		n, err := r.Read(buf[end:])
+		end += n
+		switch {
+		case err == io.EOF:
+			atEOF = true
+		case err != nil:
+			return results, fmt.Errorf("error: %w while scanning: %s after line: %d", err, name, blocked.lineNo)
+		case n == 0:
+			if emptyReads++; emptyReads >= maxEmptyReads {
+				return results, fmt.Errorf("error: %w while scanning: %s after line: %d", io.ErrNoProgress, name, blocked.lineNo)
+			}
+		default:
+			emptyReads = 0
+		}
 	}
-	applicableMatchers := matchers.filterApplicable(filePath)
-	if len(applicableMatchers) == 0 {
-		return results, nil
+}
+
+// matchLine runs all the matchers over a single line
+func (matchers contentMatchers) matchLine(name string, lineNo int, content []byte) []ContentMatchResult {
+	var results []ContentMatchResult
+	for _, m := range matchers {
+		results = append(results, m.match(name, lineNo, content)...)
 	}
-	fp, err := os.OpenFile(filePath, os.O_RDONLY, os.ModePerm)
-	defer fp.Close()
-	if err != nil {
-		return results, err
-	}
-	scanner := bufio.NewScanner(fp)
-	scanner.Buffer(bufPool, cap(bufPool))
-	lineNo := 0
-	for scanner.Scan() {
-		if scanner.Err() != nil {
-			return results, err
-		}
-		lineNo++
-		content := scanner.Bytes()
-		for _, m := range applicableMatchers {
-			matches := m.Exp.FindAll(content, -1)
-			if len(matches) == 0 {
+	return results
+}
+
+// match runs the matcher over a line or the value of a structured file
+func (m contentMatcher) match(name string, lineNo int, content []byte) []ContentMatchResult {
+	if m.redactor != nil || m.entropy != nil || m.validator != nil {
+		return m.matchSubmatches(name, lineNo, content)
+	}
+	var results []ContentMatchResult
+	for _, match := range m.Exp.FindAll(content, -1) {
+		results = append(results, ContentMatchResult{ExpID: m.ID, FilePath: name, LineNumber: lineNo, MatchString: string(match), Rule: m.rule})
+	}
+	return results
+}
+
+// matchSubmatches runs the matcher over a line when the matches are filtered, validated or redacted
+func (m contentMatcher) matchSubmatches(name string, lineNo int, content []byte) []ContentMatchResult {
+	var results []ContentMatchResult
+	for _, loc := range m.Exp.FindAllSubmatchIndex(content, -1) {
+		if m.entropy != nil && !m.entropy.check(content, loc) {
+			continue
+		}
+		result := ContentMatchResult{ExpID: m.ID, FilePath: name, LineNumber: lineNo, MatchString: string(content[loc[0]:loc[1]]), Rule: m.rule}
+		if m.validator != nil {
+			result.Validation = m.validate(result, content, loc)
+			if result.Validation == ValidationInvalid {
 				continue
 			}
-			for _, match := range matches {
-				results = append(results, ContentMatchResult{ExpID: m.ID, FilePath: filePath, LineNumber: lineNo, MatchString: string(match)})
-			}
 		}
+		if m.redactor != nil {
+			result.MatchString = m.redactor.redactMatch(content, loc)
+		}
+		results = append(results, result)
 	}
-	return results, nil
+	return results
 }
 
 func (matchers contentMatchers) filterApplicable(filePath string) contentMatchers {
 	applicableMatchers := make(contentMatchers, 0)
 	pathBytes := []byte(filePath)
+	// the results of the named file filters, shared by the matchers referring to them
+	var shared map[*fileMatcher]bool
+	structured := getStructuredFormat(filePath) != formatUnstructured
 	for _, m := range matchers {
+		if m.keyExp != nil && !structured {
+			continue
+		}
 		if m.fileMatcher == nil {
 			applicableMatchers = append(applicableMatchers, m)
 			continue
 		}
+		if m.sharedFilter {
+			matched, ok := shared[m.fileMatcher]
+			if !ok {
+				if shared == nil {
+					shared = make(map[*fileMatcher]bool)
+				}
+				matched = m.fileMatcher.match(pathBytes)
+				shared[m.fileMatcher] = matched
+			}
+			if matched {
+				applicableMatchers = append(applicableMatchers, m)
+			}
+			continue
+		}
 		if m.fileMatcher.match(pathBytes) {
 			applicableMatchers = append(applicableMatchers, m)
 		}
@@ -85,10 +237,24 @@ func (matchers contentMatchers) filterApplicable(filePath string) contentMatcher
 	return applicableMatchers
 }
 
-func newContentMatcher(e ContentMatchExp) (contentMatcher, []error) {
+func newContentMatcher(e ContentMatchExp, filters fileFilters) (contentMatcher, []error) {
 	m := contentMatcher{}
 	errs := make([]error, 0)
-	if e.FileFilterEnabled {
+	if err := e.RuleMetadata.validate(); err != nil {
+		errs = append(errs, fmt.Errorf("error: %v while compiling content match metadata for id: %s", err, e.ID))
+	}
+	switch {
+	case e.FileFilter != "" && e.FileFilterEnabled:
+		errs = append(errs, fmt.Errorf("error: %w: file_filter and file_filter_enabled are both set for content match id: %s", ErrInvalidArgument, e.ID))
+	case e.FileFilter != "":
+		fm, ok := filters[e.FileFilter]
+		if !ok {
+			errs = append(errs, fmt.Errorf("error: %w: unknown file filter: %s for content match id: %s", ErrInvalidArgument, e.FileFilter, e.ID))
+		} else {
+			m.fileMatcher = fm
+			m.sharedFilter = true
+		}
+	case e.FileFilterEnabled:
 		e.FileMatchExp.ID = e.ID
 		fm, err := newFileMatcher(e.FileMatchExp)
 		if err != nil {
@@ -102,21 +268,78 @@ func newContentMatcher(e ContentMatchExp) (contentMatcher, []error) {
 		errs = append(errs, fmt.Errorf("error: %v while compiling content match exp for id: %s", err, e.ID))
 		return m, errs
 	}
+	redactor, err := newRedactor(e.Redact, compiled)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("error: %v while compiling content match redaction for id: %s", err, e.ID))
+		return m, errs
+	}
+	entropy, err := newEntropyChecker(e.Entropy, compiled)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("error: %v while compiling content match entropy filter for id: %s", err, e.ID))
+		return m, errs
+	}
+	validator, err := getBuiltinValidator(e.Validator)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("error: %v while compiling content match validator for id: %s", err, e.ID))
+		return m, errs
+	}
+	validatorGroup, err := subexpIndex(compiled, e.ValidatorGroup)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("error: %v while compiling content match validator group for id: %s", err, e.ID))
+		return m, errs
+	}
+	splitKey, split, err := newSplitFunc(e.LineSplit, e.LineDelimiter)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("error: %v while compiling content match line split for id: %s", err, e.ID))
+		return m, errs
+	}
+	if e.KeyExp != "" {
+		m.keyExp, err = regexp.Compile(e.KeyExp)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error: %v while compiling content match key exp for id: %s", err, e.ID))
+			return m, errs
+		}
+	}
 	m.Exp = compiled
 	m.ID = e.ID
+	m.redactor = redactor
+	m.entropy = entropy
+	m.validator = validator
+	m.validatorGroup = validatorGroup
+	m.rule = e.RuleMetadata.resultRule()
+	m.splitKey = splitKey
+	m.split = split
 	//m.FlipMatch = e.FlipMatch
 	return m, errs
 }
 
-//buildContentMatchers is a helper function to compile content match expressions
-func buildContentMatchers(exps []ContentMatchExp) (contentMatchers, []error) {
+// buildFileFilters compiles the named file filters once for all the content rules referring to them
+func buildFileFilters(exps []FileMatchExp) (fileFilters, []error) {
+	filters := make(fileFilters, len(exps))
+	errs := make([]error, 0)
+	for _, e := range exps {
+		fm, err := newFileMatcher(e)
+		if err == nil {
+			err = e.Encoding.validate()
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error: %v while compiling file filter: %s", err, e.ID))
+			continue
+		}
+		filters[e.ID] = &fm
+	}
+	return filters, errs
+}
+
+// buildContentMatchers is a helper function to compile content match expressions
+func buildContentMatchers(exps []ContentMatchExp, filters fileFilters) (contentMatchers, []error) {
 	matchers := make(contentMatchers, 0, len(exps))
 	errs := make([]error, 0)
 	if len(exps) == 0 {
 		return matchers, errs
 	}
 	for _, e := range exps {
-		matcher, errsin := newContentMatcher(e)
+		matcher, errsin := newContentMatcher(e, filters)
 		if len(errsin) > 0 {
 			errs = append(errs, errsin...)
 			continue
@@ -0,0 +1,202 @@
+package mres
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+const defaultDecompressMaxSize = 1024 * 1024 * 1024
+
+type (
+	// DecompressOptions controls decompression of compressed files before matching, set it with
+	// Scanner.SetDecompressOptions. gzip, bzip2, zstd and xz are detected by their magic bytes,
+	// so rotated logs like app.log.3.gz are scanned whatever their extension is.
+	DecompressOptions struct {
+		// Enabled decompresses compressed files before the content expressions are matched
+		Enabled bool `json:"enabled,omitempty"`
+		// MaxSize is the maximum decompressed size of a file in bytes, defaults to 1GB
+		MaxSize int64 `json:"max_size,omitempty"`
+	}
+
+	compressionFormat int
+
+	// maxSizeReader fails with ErrDecompressLimitExceeded once more than max bytes are read
+	maxSizeReader struct {
+		r    io.Reader
+		name string
+		left int64
+		max  int64
+	}
+
+	// replayReader records the bytes read from r until stopped, so they can be read again
+	replayReader struct {
+		r        io.Reader
+		recorded bytes.Buffer
+		stopped  bool
+	}
+)
+
+const (
+	compressionNone compressionFormat = iota
+	compressionGzip
+	compressionBzip2
+	compressionZstd
+	compressionXz
+)
+
+var compressionMagics = []struct {
+	format compressionFormat
+	magic  []byte
+}{
+	{compressionGzip, []byte{0x1f, 0x8b}},
+	{compressionZstd, []byte{0x28, 0xb5, 0x2f, 0xfd}},
+	{compressionXz, []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}},
+}
+
+// bzip2BlockMagic starts the first block of a bzip2 stream, after the BZh signature and the block size
+var bzip2BlockMagic = []byte{0x31, 0x41, 0x59, 0x26, 0x53, 0x59}
+
+// SetDecompressOptions sets the options used while decompressing files
+func (s *Scanner) SetDecompressOptions(opts DecompressOptions) {
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = defaultDecompressMaxSize
+	}
+	s.decompressOptions = opts
+}
+
+// detectCompression detects the compression format by the magic bytes at the start of the content
+func detectCompression(header []byte) compressionFormat {
+	for _, m := range compressionMagics {
+		if bytes.HasPrefix(header, m.magic) {
+			return m.format
+		}
+	}
+	if isBzip2Header(header) {
+		return compressionBzip2
+	}
+	return compressionNone
+}
+
+// isBzip2Header checks the BZh signature, the block size from 1 to 9 and the magic of the first block,
+// text files may start with BZh
+func isBzip2Header(header []byte) bool {
+	return len(header) >= 10 && bytes.HasPrefix(header, []byte("BZh")) && header[3] >= '1' && header[3] <= '9' &&
+		bytes.Equal(header[4:10], bzip2BlockMagic)
+}
+
+// matchFileContent runs the content matchers over the file, decompressing it if enabled
+func (s *Scanner) matchFileContent(open fileOpener, job scanJob, bufPool []byte) ([]ContentMatchResult, error) {
+	filePath := job.path
+	applicableMatchers := s.contentMatchers.filterApplicable(filePath)
+	if len(applicableMatchers) == 0 {
+		return make([]ContentMatchResult, 0), nil
+	}
+	fp, err := open(job)
+	if err != nil {
+		return make([]ContentMatchResult, 0), err
+	}
+	defer fp.Close()
+	if !s.decompressOptions.Enabled {
+		return s.matchDecoded(applicableMatchers, filePath, fp, bufPool)
+	}
+	r, closeFn := s.decompressReader(filePath, fp)
+	defer closeFn()
+	return s.matchDecoded(applicableMatchers, filePath, r, bufPool)
+}
+
+// decompressReader wraps r with a decompression stream if the content is compressed.
+// Content looking compressed that the stream fails to start or to read first is scanned as is.
+// The returned function releases the stream and must be called once done.
+func (s *Scanner) decompressReader(name string, r io.Reader) (io.Reader, func()) {
+	noop := func() {}
+	br := bufio.NewReader(r)
+	header, _ := br.Peek(10)
+	format := detectCompression(header)
+	if format == compressionNone {
+		return br, noop
+	}
+	rr := &replayReader{r: br}
+	var dr io.Reader
+	var err error
+	closeFn := noop
+	switch format {
+	case compressionGzip:
+		var gr *gzip.Reader
+		if gr, err = gzip.NewReader(rr); err == nil {
+			dr, closeFn = gr, func() { gr.Close() }
+		}
+	case compressionBzip2:
+		dr = bzip2.NewReader(rr)
+	case compressionZstd:
+		var zr *zstd.Decoder
+		if zr, err = zstd.NewReader(rr, zstd.WithDecoderConcurrency(1)); err == nil {
+			dr, closeFn = zr, zr.Close
+		}
+	case compressionXz:
+		dr, err = xz.NewReader(rr)
+	}
+	var first []byte
+	if err == nil {
+		first = make([]byte, 512)
+		var n int
+		n, err = io.ReadAtLeast(dr, first, 1)
+		first = first[:n]
+		if err == io.EOF {
+			err = nil
+		}
+	}
+	if err != nil {
+		closeFn()
+		s.logger.Debug(fmt.Sprintf("File: %s is not compressed as its header says. err: %v, scanning it as is", name, err))
+		return rr.replay(), noop
+	}
+	rr.stop()
+	s.logger.Debug(fmt.Sprintf("Decompressing file: %s", name))
+	max := s.decompressOptions.MaxSize
+	return &maxSizeReader{r: io.MultiReader(bytes.NewReader(first), dr), name: name, left: max, max: max}, closeFn
+}
+
+func (rr *replayReader) Read(p []byte) (int, error) {
+	n, err := rr.r.Read(p)
+	if !rr.stopped {
+		rr.recorded.Write(p[:n])
+	}
+	return n, err
+}
+
+// stop stops recording and drops the bytes recorded
+func (rr *replayReader) stop() {
+	rr.stopped = true
+	rr.recorded = bytes.Buffer{}
+}
+
+// replay returns a reader of the bytes recorded followed by the rest of r
+func (rr *replayReader) replay() io.Reader {
+	rr.stopped = true
+	return io.MultiReader(bytes.NewReader(rr.recorded.Bytes()), rr.r)
+}
+
+func (m *maxSizeReader) Read(p []byte) (int, error) {
+	if m.left <= 0 {
+		// the content is exactly at the limit unless there is more to read
+		var b [1]byte
+		n, err := m.r.Read(b[:])
+		if n == 0 {
+			return 0, err
+		}
+		return 0, fmt.Errorf("%w: file: %s decompresses to more than %d bytes", ErrDecompressLimitExceeded, m.name, m.max)
+	}
+	if int64(len(p)) > m.left {
+		p = p[:m.left]
+	}
+	n, err := m.r.Read(p)
+	m.left -= int64(n)
+	return n, err
+}
@@ -0,0 +1,117 @@
+package mres
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+func compressTestContent(t *testing.T, format compressionFormat, content string) []byte {
+	buf := new(bytes.Buffer)
+	var w io.WriteCloser
+	var err error
+	switch format {
+	case compressionGzip:
+		w = gzip.NewWriter(buf)
+	case compressionZstd:
+		w, err = zstd.NewWriter(buf)
+	case compressionXz:
+		w, err = xz.NewWriter(buf)
+	}
+	if err != nil {
+		t.Fatalf("error while creating compressor. err: %v", err)
+	}
+	w.Write([]byte(content))
+	if err := w.Close(); err != nil {
+		t.Fatalf("error while compressing. err: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestScanner_Decompress(t *testing.T) {
+	content := "line one\ntoken: secret-log\n"
+	files := map[string][]byte{
+		"app.log.1.gz":  compressTestContent(t, compressionGzip, content),
+		"app.log.2.zst": compressTestContent(t, compressionZstd, content),
+		"app.log.3.xz":  compressTestContent(t, compressionXz, content),
+		"renamed.log":   compressTestContent(t, compressionGzip, content),
+		"plain.log":     []byte(content),
+		// the content starts with magic bytes but is not compressed
+		"bzh.log": []byte("BZh9 notes\ntoken: secret-log\n"),
+		"fake.gz": []byte("\x1f\x8b notes\ntoken: secret-log\n"),
+	}
+	root := newTestTree(t, map[string]string{})
+	defer os.RemoveAll(root)
+	for name, b := range files {
+		if err := ioutil.WriteFile(filepath.Join(root, name), b, 0644); err != nil {
+			t.Fatalf("error while writing file. err: %v", err)
+		}
+	}
+	exps := Expressions{ContentMatchExps: []ContentMatchExp{{ID: "secret", Exp: `secret-\w+`}}}
+	tests := []struct {
+		name      string
+		opts      DecompressOptions
+		wantCount int
+		wantErr   error
+	}{
+		// the raw content is scanned, its matches depend on the compression
+		{name: "decompression disabled", opts: DecompressOptions{}, wantCount: -1},
+		{name: "decompression enabled", opts: DecompressOptions{Enabled: true}, wantCount: 7},
+		{name: "size limit", opts: DecompressOptions{Enabled: true, MaxSize: 10}, wantCount: 3, wantErr: ErrDecompressLimitExceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := NewScanner(exps)
+			s.SetDecompressOptions(tt.opts)
+			got, errs := s.Scan(context.TODO(), []string{root}, 2)
+			if tt.wantCount < 0 {
+				return
+			}
+			if len(got.ContentMatches) != tt.wantCount {
+				t.Errorf("Scanner.Scan() content matches = %v, want %v", got.ContentMatches, tt.wantCount)
+			}
+			for _, r := range got.ContentMatches {
+				if r.LineNumber != 2 || r.MatchString != "secret-log" {
+					t.Errorf("Scanner.Scan() content match = %v, want line 2 and secret-log", r)
+				}
+			}
+			if tt.wantErr == nil && len(errs) > 0 {
+				t.Errorf("Scanner.Scan() errors = %v", errs)
+			}
+			if tt.wantErr != nil && (len(errs) == 0 || !errors.Is(errs[0], tt.wantErr)) {
+				t.Errorf("Scanner.Scan() errors = %v, want %v", errs, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_detectCompression(t *testing.T) {
+	tests := []struct {
+		name   string
+		header []byte
+		want   compressionFormat
+	}{
+		{name: "gzip", header: []byte{0x1f, 0x8b, 0x08, 0, 0, 0, 0, 0, 0, 0}, want: compressionGzip},
+		{name: "bzip2", header: append([]byte("BZh9"), bzip2BlockMagic...), want: compressionBzip2},
+		{name: "bzip2 block size", header: append([]byte("BZh0"), bzip2BlockMagic...), want: compressionNone},
+		{name: "bzip2 short", header: []byte("BZh9"), want: compressionNone},
+		{name: "text starting with BZh", header: []byte("BZh91AY&SX"), want: compressionNone},
+		{name: "text", header: []byte("token: x"), want: compressionNone},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := detectCompression(tt.header); got != tt.want {
+				t.Errorf("detectCompression() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package mres
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type (
+	// DiffOptions controls how the results of two scans are compared
+	DiffOptions struct {
+		// OldRoot and NewRoot are the scanned paths of the old and the new results. The file paths are
+		// made relative to them so scans of different checkouts of the same tree can be compared.
+		OldRoot string `json:"old_root,omitempty"`
+		NewRoot string `json:"new_root,omitempty"`
+	}
+
+	// DiffResult is the difference between the results of two scans. Added and Unchanged hold the new results,
+	// Removed holds the old ones.
+	DiffResult struct {
+		Added     MatchResult `json:"added"`
+		Removed   MatchResult `json:"removed"`
+		Unchanged MatchResult `json:"unchanged"`
+	}
+)
+
+// Fingerprint identifies the file match across scans
+func (r FileMatchResult) Fingerprint() string {
+	return fingerprint(r.ExpID, r.FilePath, "")
+}
+
+// Fingerprint identifies the content match across scans. The line number is not part of it so a match
+// moving to another line keeps its fingerprint, the whitespace around and inside the match is normalized.
+func (r ContentMatchResult) Fingerprint() string {
+	return fingerprint(r.ExpID, r.FilePath, normalizeMatch(r.MatchString))
+}
+
+func fingerprint(expID string, filePath string, match string) string {
+	sum := sha256.Sum256([]byte(expID + "\x00" + filepath.ToSlash(filePath) + "\x00" + match))
+	return hex.EncodeToString(sum[:16])
+}
+
+// normalizeMatch trims the match and collapses its whitespace
+func normalizeMatch(match string) string {
+	return strings.Join(strings.Fields(match), " ")
+}
+
+// LoadMatchResult reads the results of a scan from a JSON file
+func LoadMatchResult(path string) (MatchResult, error) {
+	result := MatchResult{}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return result, err
+	}
+	if err := json.Unmarshal(content, &result); err != nil {
+		return result, fmt.Errorf("error: %w while parsing results: %s", err, path)
+	}
+	return result, nil
+}
+
+// DiffResults compares the results of two scans by the fingerprints of the matches. Matches with the same
+// fingerprint are paired one to one, so an extra copy of a match in a file is added.
+func DiffResults(oldResult MatchResult, newResult MatchResult, opts DiffOptions) DiffResult {
+	diff := DiffResult{
+		Added:     MatchResult{FileMatches: make([]FileMatchResult, 0), ContentMatches: make([]ContentMatchResult, 0)},
+		Removed:   MatchResult{FileMatches: make([]FileMatchResult, 0), ContentMatches: make([]ContentMatchResult, 0)},
+		Unchanged: MatchResult{FileMatches: make([]FileMatchResult, 0), ContentMatches: make([]ContentMatchResult, 0)},
+	}
+	oldFiles := make(map[string]int)
+	for _, r := range oldResult.FileMatches {
+		r.FilePath = relativeTo(opts.OldRoot, r.FilePath)
+		oldFiles[r.Fingerprint()]++
+	}
+	for _, r := range newResult.FileMatches {
+		r.FilePath = relativeTo(opts.NewRoot, r.FilePath)
+		if id := r.Fingerprint(); oldFiles[id] > 0 {
+			oldFiles[id]--
+			diff.Unchanged.FileMatches = append(diff.Unchanged.FileMatches, r)
+			continue
+		}
+		diff.Added.FileMatches = append(diff.Added.FileMatches, r)
+	}
+	for _, r := range oldResult.FileMatches {
+		r.FilePath = relativeTo(opts.OldRoot, r.FilePath)
+		if id := r.Fingerprint(); oldFiles[id] > 0 {
+			oldFiles[id]--
+			diff.Removed.FileMatches = append(diff.Removed.FileMatches, r)
+		}
+	}
+	oldContents := make(map[string]int)
+	for _, r := range oldResult.ContentMatches {
+		r.FilePath = relativeTo(opts.OldRoot, r.FilePath)
+		oldContents[r.Fingerprint()]++
+	}
+	for _, r := range newResult.ContentMatches {
+		r.FilePath = relativeTo(opts.NewRoot, r.FilePath)
+		if id := r.Fingerprint(); oldContents[id] > 0 {
+			oldContents[id]--
+			diff.Unchanged.ContentMatches = append(diff.Unchanged.ContentMatches, r)
+			continue
+		}
+		diff.Added.ContentMatches = append(diff.Added.ContentMatches, r)
+	}
+	for _, r := range oldResult.ContentMatches {
+		r.FilePath = relativeTo(opts.OldRoot, r.FilePath)
+		if id := r.Fingerprint(); oldContents[id] > 0 {
+			oldContents[id]--
+			diff.Removed.ContentMatches = append(diff.Removed.ContentMatches, r)
+		}
+	}
+	return diff
+}
+
+// relativeTo returns path relative to root, or path if it is not inside root
+func relativeTo(root string, path string) string {
+	if root == "" {
+		return path
+	}
+	prefix, entry := path, ""
+	// archive entries keep their entry name
+	if i := strings.Index(path, archiveSeparator); i >= 0 {
+		prefix, entry = path[:i], path[i:]
+	}
+	rel, err := filepath.Rel(root, prefix)
+	if err != nil || !isUnder(prefix, root) {
+		return path
+	}
+	return rel + entry
+}
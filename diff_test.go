@@ -0,0 +1,98 @@
+package mres
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDiffResults(t *testing.T) {
+	oldResult := MatchResult{
+		FileMatches: []FileMatchResult{
+			{ExpID: "pem", FilePath: "/old/repo/certs/key.pem"},
+			{ExpID: "pem", FilePath: "/old/repo/certs/old.pem"},
+		},
+		ContentMatches: []ContentMatchResult{
+			{ExpID: "xorp", FilePath: "/old/repo/config.json", LineNumber: 2, MatchString: "xorp-aaaa"},
+			{ExpID: "xorp", FilePath: "/old/repo/src/main.go", LineNumber: 3, MatchString: "token = xorp-bbbb"},
+			{ExpID: "xorp", FilePath: "/old/repo/src/main.go", LineNumber: 9, MatchString: "xorp-cccc"},
+			{ExpID: "xorp", FilePath: "/old/repo/release.zip!/app.yaml", LineNumber: 1, MatchString: "xorp-zip"},
+		},
+	}
+	newResult := MatchResult{
+		FileMatches: []FileMatchResult{
+			{ExpID: "pem", FilePath: "/new/repo/certs/key.pem"},
+		},
+		ContentMatches: []ContentMatchResult{
+			{ExpID: "xorp", FilePath: "/new/repo/config.json", LineNumber: 4, MatchString: "xorp-aaaa"},
+			{ExpID: "xorp", FilePath: "/new/repo/src/main.go", LineNumber: 5, MatchString: "token  =  xorp-bbbb "},
+			{ExpID: "xorp", FilePath: "/new/repo/src/main.go", LineNumber: 6, MatchString: "xorp-bbbb"},
+			{ExpID: "xorp", FilePath: "/new/repo/config.json", LineNumber: 7, MatchString: "xorp-aaaa"},
+			{ExpID: "xorp", FilePath: "/new/repo/release.zip!/app.yaml", LineNumber: 1, MatchString: "xorp-zip"},
+		},
+	}
+	summary := func(r MatchResult) []string {
+		s := make([]string, 0)
+		for _, f := range r.FileMatches {
+			s = append(s, f.FilePath)
+		}
+		for _, c := range r.ContentMatches {
+			s = append(s, fmt.Sprintf("%s:%d", c.FilePath, c.LineNumber))
+		}
+		return s
+	}
+	tests := []struct {
+		name          string
+		opts          DiffOptions
+		wantAdded     []string
+		wantRemoved   []string
+		wantUnchanged []string
+	}{
+		{
+			name:          "relative to the roots",
+			opts:          DiffOptions{OldRoot: "/old/repo", NewRoot: "/new/repo"},
+			wantAdded:     []string{"src/main.go:6", "config.json:7"},
+			wantRemoved:   []string{"certs/old.pem", "src/main.go:9"},
+			wantUnchanged: []string{"certs/key.pem", "config.json:4", "src/main.go:5", "release.zip!/app.yaml:1"},
+		},
+		{
+			name:          "without roots",
+			opts:          DiffOptions{},
+			wantAdded:     summary(newResult),
+			wantRemoved:   summary(oldResult),
+			wantUnchanged: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DiffResults(oldResult, newResult, tt.opts)
+			if s := summary(got.Added); !reflect.DeepEqual(s, tt.wantAdded) {
+				t.Errorf("DiffResults() added = %v, want %v", s, tt.wantAdded)
+			}
+			if s := summary(got.Removed); !reflect.DeepEqual(s, tt.wantRemoved) {
+				t.Errorf("DiffResults() removed = %v, want %v", s, tt.wantRemoved)
+			}
+			if s := summary(got.Unchanged); !reflect.DeepEqual(s, tt.wantUnchanged) {
+				t.Errorf("DiffResults() unchanged = %v, want %v", s, tt.wantUnchanged)
+			}
+		})
+	}
+}
+
+func TestLoadMatchResult(t *testing.T) {
+	root := newTestTree(t, map[string]string{
+		"results.json": `{"content_matches":[{"exp_id":"xorp","file_path":"a.txt","line_number":2,"match_string":"xorp-1"}]}`,
+		"invalid.json": `{"content_matches":[`,
+	})
+	defer os.RemoveAll(root)
+	got, err := LoadMatchResult(filepath.Join(root, "results.json"))
+	want := MatchResult{ContentMatches: []ContentMatchResult{{ExpID: "xorp", FilePath: "a.txt", LineNumber: 2, MatchString: "xorp-1"}}}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("LoadMatchResult() = %v, %v, want %v", got, err, want)
+	}
+	if _, err := LoadMatchResult(filepath.Join(root, "invalid.json")); err == nil {
+		t.Errorf("LoadMatchResult(invalid.json) error = nil, want error")
+	}
+}
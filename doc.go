@@ -7,6 +7,18 @@ import (
 var (
 	//ErrInvalidArgument ...
 	ErrInvalidArgument = errors.New("invalid argument")
+	//ErrArchiveLimitExceeded is reported when an archive crosses one of the ArchiveOptions limits
+	ErrArchiveLimitExceeded = errors.New("archive limit exceeded")
+	//ErrDecompressLimitExceeded is reported when a compressed file decompresses to more than DecompressOptions.MaxSize
+	ErrDecompressLimitExceeded = errors.New("decompress limit exceeded")
+	//ErrBlobTooLarge is reported when a git blob is larger than GitScanOptions.MaxBlobSize
+	ErrBlobTooLarge = errors.New("blob too large")
+	//ErrFileTooLarge is reported when the content of a file is not scanned because it is larger than FileLimits.MaxSize
+	ErrFileTooLarge = errors.New("file too large")
+	//ErrFileTimeout is reported when the scan of a file is aborted because it took longer than FileLimits.Timeout
+	ErrFileTimeout = errors.New("file scan timeout")
+	//ErrStructuredTooLarge is reported when a structured file is too large to be parsed, its lines are still scanned
+	ErrStructuredTooLarge = errors.New("structured file too large")
 
 	errReceivedCancellation = errors.New("received cancellation")
 )
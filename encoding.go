@@ -0,0 +1,266 @@
+package mres
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+const (
+	// EncodingUTF8 content is matched as it is, it is not reported in the results
+	EncodingUTF8 Encoding = "utf-8"
+	// EncodingUTF16LE is detected by its BOM or by the zero high bytes of ASCII characters
+	EncodingUTF16LE Encoding = "utf-16le"
+	// EncodingUTF16BE is detected by its BOM or by the zero high bytes of ASCII characters
+	EncodingUTF16BE Encoding = "utf-16be"
+	// EncodingLatin1 is assumed for text which is not valid UTF-8, the valid UTF-8 runes of detected
+	// Latin-1 text are kept as they are
+	EncodingLatin1 Encoding = "latin-1"
+
+	// encodingSampleSize is the size of the start of the content the encoding is detected with
+	encodingSampleSize = 4096
+)
+
+var (
+	bomUTF8    = []byte{0xef, 0xbb, 0xbf}
+	bomUTF16LE = []byte{0xff, 0xfe}
+	bomUTF16BE = []byte{0xfe, 0xff}
+)
+
+type (
+	// Encoding is the text encoding of a file, content in other encodings than UTF-8 is transcoded to UTF-8
+	// before it is matched. Named file filters can force the encoding of the files they match with
+	// FileMatchExp.Encoding, the encoding is detected otherwise.
+	Encoding string
+
+	// encodingFilter forces the encoding of the files matched by a named file filter
+	encodingFilter struct {
+		matcher  *fileMatcher
+		encoding Encoding
+	}
+
+	// decodingReader transcodes the runes returned by next to UTF-8
+	decodingReader struct {
+		next    func() (rune, error)
+		pending []byte
+		buf     [utf8.UTFMax]byte
+	}
+
+	// utf16Decoder reads the runes of UTF-16 content
+	utf16Decoder struct {
+		r     *bufio.Reader
+		order binary.ByteOrder
+	}
+)
+
+// validate checks the encoding is a known one, an empty encoding is detected
+func (e Encoding) validate() error {
+	switch e {
+	case "", EncodingUTF8, EncodingUTF16LE, EncodingUTF16BE, EncodingLatin1:
+		return nil
+	}
+	return fmt.Errorf("%w: unknown encoding: %s", ErrInvalidArgument, e)
+}
+
+// buildEncodingFilters returns the named file filters forcing an encoding, in their order
+func buildEncodingFilters(exps []FileMatchExp, filters fileFilters) []encodingFilter {
+	encodingFilters := make([]encodingFilter, 0)
+	for _, e := range exps {
+		if m, ok := filters[e.ID]; ok && e.Encoding != "" {
+			encodingFilters = append(encodingFilters, encodingFilter{matcher: m, encoding: e.Encoding})
+		}
+	}
+	return encodingFilters
+}
+
+// forcedEncoding returns the encoding of the first named file filter with an encoding matching name, "" if none
+func (s *Scanner) forcedEncoding(name string) Encoding {
+	pathBytes := []byte(name)
+	for _, f := range s.encodingFilters {
+		if f.matcher.match(pathBytes) {
+			return f.encoding
+		}
+	}
+	return ""
+}
+
+// matchDecoded runs the matchers over the content read from r transcoded to UTF-8, the structured ones over
+// its values and the others line by line. The results carry the encoding of the content if it is not UTF-8.
+func (s *Scanner) matchDecoded(matchers contentMatchers, name string, r io.Reader, bufPool []byte) ([]ContentMatchResult, error) {
+	dr, encoding := s.decodeReader(name, r)
+	limiter := newFileMatchLimiter(s.matchLimits)
+	lineMatchers, structuredMatchers := matchers.splitStructured()
+	results := make([]ContentMatchResult, 0)
+	var structuredErr error
+	if len(structuredMatchers) > 0 {
+		results, dr, structuredErr = structuredMatchers.matchStructured(name, dr, bufPool, limiter)
+		if limiter != nil {
+			lineMatchers = limiter.active(lineMatchers)
+			if limiter.done {
+				lineMatchers = nil
+			}
+		}
+	}
+	var err error
+	if len(lineMatchers) > 0 {
+		var lineResults []ContentMatchResult
+		lineResults, err = lineMatchers.matchLines(name, dr, bufPool, limiter)
+		results = append(results, lineResults...)
+	}
+	if err == nil {
+		err = structuredErr
+	}
+	if encoding != EncodingUTF8 {
+		for i := range results {
+			results[i].Encoding = encoding
+		}
+	}
+	return results, err
+}
+
+// decodeReader returns the content read from r transcoded to UTF-8 and its encoding, forced or detected.
+// The BOM is dropped.
+func (s *Scanner) decodeReader(name string, r io.Reader) (io.Reader, Encoding) {
+	br := bufio.NewReaderSize(r, encodingSampleSize)
+	// read errors are returned by the reads of the content
+	sample, _ := br.Peek(encodingSampleSize)
+	encoding := s.forcedEncoding(name)
+	forced := encoding != ""
+	if !forced {
+		encoding = detectEncoding(sample)
+		if encoding != EncodingUTF8 {
+			s.logger.Debug(fmt.Sprintf("Detected encoding: %s of file: %s", encoding, name))
+		}
+	}
+	switch encoding {
+	case EncodingUTF16LE:
+		if bytes.HasPrefix(sample, bomUTF16LE) {
+			br.Discard(len(bomUTF16LE))
+		}
+		return &decodingReader{next: (&utf16Decoder{r: br, order: binary.LittleEndian}).next}, encoding
+	case EncodingUTF16BE:
+		if bytes.HasPrefix(sample, bomUTF16BE) {
+			br.Discard(len(bomUTF16BE))
+		}
+		return &decodingReader{next: (&utf16Decoder{r: br, order: binary.BigEndian}).next}, encoding
+	case EncodingLatin1:
+		if !forced {
+			// a stray byte in UTF-8 text does not garble its other runes
+			return &decodingReader{next: func() (rune, error) { return nextUTF8OrLatin1(br) }}, encoding
+		}
+		return &decodingReader{next: func() (rune, error) {
+			b, err := br.ReadByte()
+			return rune(b), err
+		}}, encoding
+	}
+	if bytes.HasPrefix(sample, bomUTF8) {
+		br.Discard(len(bomUTF8))
+	}
+	return br, EncodingUTF8
+}
+
+// detectEncoding detects the encoding of the content by its BOM, the zero bytes of UTF-16 ASCII characters
+// and its UTF-8 validity. Binary content, with zero bytes, is matched as it is.
+func detectEncoding(sample []byte) Encoding {
+	switch {
+	case bytes.HasPrefix(sample, bomUTF8):
+		return EncodingUTF8
+	case bytes.HasPrefix(sample, bomUTF16LE):
+		return EncodingUTF16LE
+	case bytes.HasPrefix(sample, bomUTF16BE):
+		return EncodingUTF16BE
+	}
+	pairs := len(sample) / 2
+	zeroEven, zeroOdd := 0, 0
+	for i := 0; i+1 < len(sample); i += 2 {
+		if sample[i] == 0 {
+			zeroEven++
+		}
+		if sample[i+1] == 0 {
+			zeroOdd++
+		}
+	}
+	// mostly ASCII text in UTF-16 has a zero high byte in many code units and rarely a zero low byte,
+	// the zero bytes of binary content are spread evenly
+	switch {
+	case pairs > 0 && zeroOdd*10 >= pairs*3 && zeroEven*4 < zeroOdd:
+		return EncodingUTF16LE
+	case pairs > 0 && zeroEven*10 >= pairs*3 && zeroOdd*4 < zeroEven:
+		return EncodingUTF16BE
+	case zeroEven > 0 || zeroOdd > 0 || validUTF8Prefix(sample):
+		return EncodingUTF8
+	}
+	return EncodingLatin1
+}
+
+// validUTF8Prefix reports if the sample is valid UTF-8, a rune cut at the end of the sample is allowed
+func validUTF8Prefix(sample []byte) bool {
+	for len(sample) > 0 {
+		r, size := utf8.DecodeRune(sample)
+		if r == utf8.RuneError && size == 1 {
+			return len(sample) < utf8.UTFMax && !utf8.FullRune(sample)
+		}
+		sample = sample[size:]
+	}
+	return true
+}
+
+// nextUTF8OrLatin1 returns the next valid UTF-8 rune, or the next byte as a Latin-1 character
+func nextUTF8OrLatin1(br *bufio.Reader) (rune, error) {
+	// a rune cut by the end of the content is read as Latin-1
+	next, err := br.Peek(utf8.UTFMax)
+	if len(next) == 0 {
+		return 0, err
+	}
+	r, size := utf8.DecodeRune(next)
+	if r == utf8.RuneError && size == 1 {
+		r = rune(next[0])
+	}
+	br.Discard(size)
+	return r, nil
+}
+
+func (d *decodingReader) Read(p []byte) (int, error) {
+	n := copy(p, d.pending)
+	d.pending = d.pending[n:]
+	for n < len(p) {
+		r, err := d.next()
+		if err != nil {
+			return n, err
+		}
+		size := utf8.EncodeRune(d.buf[:], r)
+		copied := copy(p[n:], d.buf[:size])
+		n += copied
+		if copied < size {
+			d.pending = d.buf[copied:size]
+		}
+	}
+	return n, nil
+}
+
+// next returns the next rune, invalid code units are utf8.RuneError
+func (d *utf16Decoder) next() (rune, error) {
+	var unit [2]byte
+	if _, err := io.ReadFull(d.r, unit[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			// a trailing odd byte
+			return utf8.RuneError, nil
+		}
+		return 0, err
+	}
+	r := rune(d.order.Uint16(unit[:]))
+	if !utf16.IsSurrogate(r) {
+		return r, nil
+	}
+	if low, err := d.r.Peek(2); err == nil {
+		if decoded := utf16.DecodeRune(r, rune(d.order.Uint16(low))); decoded != utf8.RuneError {
+			d.r.Discard(2)
+			return decoded, nil
+		}
+	}
+	return utf8.RuneError, nil
+}
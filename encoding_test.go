@@ -0,0 +1,113 @@
+package mres
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"unicode/utf16"
+)
+
+// encodeUTF16 encodes s in UTF-16 with the byte order, little endian if le is set
+func encodeUTF16(s string, le bool) []byte {
+	content := make([]byte, 0)
+	for _, unit := range utf16.Encode([]rune(s)) {
+		if le {
+			content = append(content, byte(unit), byte(unit>>8))
+		} else {
+			content = append(content, byte(unit>>8), byte(unit))
+		}
+	}
+	return content
+}
+
+func Test_detectEncoding(t *testing.T) {
+	tests := []struct {
+		name   string
+		sample []byte
+		want   Encoding
+	}{
+		{name: "empty", sample: []byte{}, want: EncodingUTF8},
+		{name: "ascii", sample: []byte("token: xorp-1\n"), want: EncodingUTF8},
+		{name: "utf-8", sample: []byte("clé: xorp-1\n"), want: EncodingUTF8},
+		{name: "utf-8 cut at the end", sample: []byte("clé: é")[:8], want: EncodingUTF8},
+		{name: "utf-8 bom", sample: append([]byte{0xef, 0xbb, 0xbf}, "a"...), want: EncodingUTF8},
+		{name: "utf-16le bom", sample: append([]byte{0xff, 0xfe}, encodeUTF16("a", true)...), want: EncodingUTF16LE},
+		{name: "utf-16be bom", sample: append([]byte{0xfe, 0xff}, encodeUTF16("a", false)...), want: EncodingUTF16BE},
+		{name: "utf-16le", sample: encodeUTF16("token=xorp-1\r\n", true), want: EncodingUTF16LE},
+		{name: "utf-16be", sample: encodeUTF16("token=xorp-1\r\n", false), want: EncodingUTF16BE},
+		{name: "latin-1", sample: []byte("cl\xe9: xorp-1\n"), want: EncodingLatin1},
+		{name: "binary", sample: []byte("\x00\x01\x02\xff\xfe\x00\x10xorp-1"), want: EncodingUTF8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := detectEncoding(tt.sample); got != tt.want {
+				t.Errorf("detectEncoding() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScanner_ScanReaderEncodings(t *testing.T) {
+	exps := Expressions{
+		ContentMatchExps: []ContentMatchExp{{ID: "key", Exp: `clé=xorp-\w+`}},
+		FileFilters:      []FileMatchExp{{ID: "legacy", Exp: `\.ini$`, Encoding: EncodingUTF16BE}},
+	}
+	tests := []struct {
+		name         string
+		fileName     string
+		content      []byte
+		wantLines    []int
+		wantEncoding Encoding
+	}{
+		{name: "utf-8", fileName: "a.cfg", content: []byte("x\nclé=xorp-1\n"), wantLines: []int{2}, wantEncoding: ""},
+		{name: "utf-8 bom", fileName: "a.cfg", content: append([]byte{0xef, 0xbb, 0xbf}, "clé=xorp-1\n"...), wantLines: []int{1}, wantEncoding: ""},
+		{name: "utf-16le bom", fileName: "a.cfg", content: append([]byte{0xff, 0xfe}, encodeUTF16("x\r\ny\r\nclé=xorp-1\r\n", true)...), wantLines: []int{3}, wantEncoding: EncodingUTF16LE},
+		{name: "utf-16be", fileName: "a.cfg", content: encodeUTF16("clé=xorp-1\nclé=xorp-2", false), wantLines: []int{1, 2}, wantEncoding: EncodingUTF16BE},
+		{name: "latin-1", fileName: "a.cfg", content: []byte("x\ncl\xe9=xorp-1\n"), wantLines: []int{2}, wantEncoding: EncodingLatin1},
+		{name: "utf-8 with an invalid byte", fileName: "a.cfg", content: []byte("x\xff\nclé=xorp-1\n"), wantLines: []int{2}, wantEncoding: EncodingLatin1},
+		{name: "forced by a named filter", fileName: "a.ini", content: encodeUTF16("clé=xorp-1\x00\x00\x00", false), wantLines: []int{1}, wantEncoding: EncodingUTF16BE},
+		{name: "surrogate pairs", fileName: "a.cfg", content: encodeUTF16("😀 clé=xorp-1\n", true), wantLines: []int{1}, wantEncoding: EncodingUTF16LE},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, errs := NewScanner(exps)
+			if len(errs) > 0 {
+				t.Fatalf("NewScanner() errs = %v", errs)
+			}
+			results, err := s.ScanReader(context.TODO(), tt.fileName, strings.NewReader(string(tt.content)))
+			if err != nil {
+				t.Fatalf("Scanner.ScanReader() error = %v", err)
+			}
+			lines := make([]int, 0)
+			for _, r := range results {
+				lines = append(lines, r.LineNumber)
+				if !strings.HasPrefix(r.MatchString, "clé=xorp-") || r.Encoding != tt.wantEncoding {
+					t.Errorf("Scanner.ScanReader() = %q with encoding %q, want a match with encoding %q", r.MatchString, r.Encoding, tt.wantEncoding)
+				}
+			}
+			if !reflect.DeepEqual(lines, tt.wantLines) {
+				t.Errorf("Scanner.ScanReader() lines = %v, want %v", lines, tt.wantLines)
+			}
+		})
+	}
+	if _, errs := NewScanner(Expressions{FileFilters: []FileMatchExp{{ID: "f", Exp: `x`, Encoding: "ebcdic"}}}); len(errs) == 0 {
+		t.Errorf("NewScanner() with an unknown encoding errs = nil, want an error")
+	}
+}
+
+func Test_decodingReader(t *testing.T) {
+	s, _ := NewScanner(Expressions{})
+	want := "😀 é\nxorp"
+	r, encoding := s.decodeReader("-", iotest.OneByteReader(strings.NewReader(string(encodeUTF16(want, true)))))
+	if encoding != EncodingUTF16LE {
+		t.Fatalf("Scanner.decodeReader() encoding = %v, want %v", encoding, EncodingUTF16LE)
+	}
+	// one byte reads split the runes in the output
+	got, err := io.ReadAll(iotest.OneByteReader(r))
+	if err != nil || string(got) != want {
+		t.Errorf("decodingReader.Read() = %q, %v, want %q", got, err, want)
+	}
+}
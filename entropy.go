@@ -0,0 +1,131 @@
+package mres
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+)
+
+const (
+	defaultEntropyHexThreshold    = 3.0
+	defaultEntropyBase64Threshold = 4.5
+	defaultEntropyMinLength       = 20
+)
+
+type (
+	// EntropyFilter keeps only the matches of a content rule containing a random looking token, e.g. the value of
+	// `token\s*[:=]\s*(?P<secret>\S+)`. The candidate is split in tokens of hex or base64 characters and a match is
+	// kept if the Shannon entropy of one of the tokens reaches the threshold of its charset. Zero values use the defaults.
+	EntropyFilter struct {
+		Enabled bool `json:"enabled,omitempty"`
+		// Group is the name or the index of the capture group holding the candidate, the whole match if it is not set
+		Group string `json:"group,omitempty"`
+		// HexThreshold is the minimum entropy in bits per character of hex tokens, defaults to 3.0
+		HexThreshold float64 `json:"hex_threshold,omitempty"`
+		// Base64Threshold is the minimum entropy in bits per character of base64 tokens, defaults to 4.5
+		Base64Threshold float64 `json:"base64_threshold,omitempty"`
+		// MinLength is the minimum length of a token, defaults to 20
+		MinLength int `json:"min_length,omitempty"`
+	}
+
+	// entropyChecker is a compiled EntropyFilter
+	entropyChecker struct {
+		opts  EntropyFilter
+		group int
+	}
+)
+
+// newEntropyChecker validates the entropy filter of the content rule, it returns nil if it is not enabled
+func newEntropyChecker(f EntropyFilter, exp *regexp.Regexp) (*entropyChecker, error) {
+	if !f.Enabled {
+		return nil, nil
+	}
+	if f.HexThreshold < 0 || f.Base64Threshold < 0 || f.MinLength < 0 {
+		return nil, fmt.Errorf("%w: negative entropy threshold or min_length", ErrInvalidArgument)
+	}
+	if f.HexThreshold == 0 {
+		f.HexThreshold = defaultEntropyHexThreshold
+	}
+	if f.Base64Threshold == 0 {
+		f.Base64Threshold = defaultEntropyBase64Threshold
+	}
+	if f.MinLength == 0 {
+		f.MinLength = defaultEntropyMinLength
+	}
+	group, err := subexpIndex(exp, f.Group)
+	if err != nil {
+		return nil, err
+	}
+	return &entropyChecker{opts: f, group: group}, nil
+}
+
+// check reports if the candidate of the match of content at loc, the submatch indexes of the match,
+// has a high entropy token
+func (c *entropyChecker) check(content []byte, loc []int) bool {
+	start, end := loc[2*c.group], loc[2*c.group+1]
+	if start < 0 {
+		return false
+	}
+	candidate := content[start:end]
+	tokenStart := -1
+	for i := 0; i <= len(candidate); i++ {
+		if i < len(candidate) && isBase64Char(candidate[i]) {
+			if tokenStart < 0 {
+				tokenStart = i
+			}
+			continue
+		}
+		if tokenStart >= 0 && c.checkToken(candidate[tokenStart:i]) {
+			return true
+		}
+		tokenStart = -1
+	}
+	return false
+}
+
+func (c *entropyChecker) checkToken(token []byte) bool {
+	if len(token) < c.opts.MinLength {
+		return false
+	}
+	threshold := c.opts.Base64Threshold
+	if isHex(token) {
+		threshold = c.opts.HexThreshold
+	}
+	return shannonEntropy(token) >= threshold
+}
+
+// shannonEntropy returns the entropy of data in bits per byte
+func shannonEntropy(data []byte) float64 {
+	if len(data) == 0 {
+		return 0
+	}
+	counts := [256]int{}
+	for _, b := range data {
+		counts[b]++
+	}
+	entropy := 0.0
+	size := float64(len(data))
+	for _, count := range counts {
+		if count == 0 {
+			continue
+		}
+		p := float64(count) / size
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// isBase64Char reports if b belongs to the standard or the URL safe base64 alphabet
+func isBase64Char(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9' ||
+		b == '+' || b == '/' || b == '=' || b == '-' || b == '_'
+}
+
+func isHex(token []byte) bool {
+	for _, b := range token {
+		if !(b >= '0' && b <= '9' || b >= 'a' && b <= 'f' || b >= 'A' && b <= 'F') {
+			return false
+		}
+	}
+	return true
+}
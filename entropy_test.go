@@ -0,0 +1,99 @@
+package mres
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_shannonEntropy(t *testing.T) {
+	tests := []struct {
+		data string
+		want float64
+	}{
+		{data: "", want: 0},
+		{data: "aaaa", want: 0},
+		{data: "abab", want: 1},
+		{data: "0123456789abcdef", want: 4},
+	}
+	for _, tt := range tests {
+		if got := shannonEntropy([]byte(tt.data)); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("shannonEntropy(%q) = %v, want %v", tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestScanner_ScanReaderEntropy(t *testing.T) {
+	content := strings.Join([]string{
+		`aws_secret = "wJalrXUtnFEMI/K7MDENG/bPxRfiCYEXAMPLEKEY"`,
+		`password = "this_is_my_password_for_the_service"`,
+		`api_token = "5d41402abc4b2a76b9719d911017c592"`,
+		`api_token = "deadbeefdeadbeefdeadbeefdeadbeef"`,
+		`short_token = "Xy9/Kq2+"`,
+	}, "\n")
+	keyValue := `(?i)(?:secret|password|token)\s*=\s*"(?P<value>[^"]+)"`
+	tests := []struct {
+		name    string
+		exp     string
+		filter  EntropyFilter
+		want    []int
+		wantErr bool
+	}{
+		{
+			name:   "disabled",
+			exp:    keyValue,
+			filter: EntropyFilter{Group: "value"},
+			want:   []int{1, 2, 3, 4, 5},
+		},
+		{
+			name:   "default thresholds on a named group",
+			exp:    keyValue,
+			filter: EntropyFilter{Enabled: true, Group: "value"},
+			want:   []int{1, 3},
+		},
+		{
+			name:   "lower base64 threshold",
+			exp:    keyValue,
+			filter: EntropyFilter{Enabled: true, Group: "value", Base64Threshold: 3.5},
+			want:   []int{1, 2, 3},
+		},
+		{
+			name:   "tokens of the whole match",
+			exp:    `[A-Za-z0-9+/=]{8,}`,
+			filter: EntropyFilter{Enabled: true, MinLength: 8, Base64Threshold: 2.9},
+			want:   []int{1, 3, 5},
+		},
+		{
+			name:    "unknown group",
+			exp:     keyValue,
+			filter:  EntropyFilter{Enabled: true, Group: "secret"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, errs := NewScanner(Expressions{ContentMatchExps: []ContentMatchExp{{ID: "entropy", Exp: tt.exp, Entropy: tt.filter}}})
+			if (len(errs) > 0) != tt.wantErr {
+				t.Fatalf("NewScanner() errors = %v, wantErr %v", errs, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			got, err := s.ScanReader(context.TODO(), "config.env", strings.NewReader(content))
+			if err != nil {
+				t.Fatalf("Scanner.ScanReader() error = %v", err)
+			}
+			lines := make([]int, 0)
+			for _, r := range got {
+				if len(lines) == 0 || lines[len(lines)-1] != r.LineNumber {
+					lines = append(lines, r.LineNumber)
+				}
+			}
+			if !reflect.DeepEqual(lines, tt.want) {
+				t.Errorf("Scanner.ScanReader() lines = %v, want %v (%v)", lines, tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,23 @@
+//go:build !windows
+// +build !windows
+
+package mres
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileID uniquely identifies a file on the host by device and inode
+type fileID struct {
+	dev uint64
+	ino uint64
+}
+
+func getFileID(fi os.FileInfo) (fileID, bool) {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileID{}, false
+	}
+	return fileID{dev: uint64(st.Dev), ino: uint64(st.Ino)}, true
+}
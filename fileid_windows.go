@@ -0,0 +1,19 @@
+//go:build windows
+// +build windows
+
+package mres
+
+import (
+	"os"
+)
+
+// fileID uniquely identifies a file on the host by device and inode
+type fileID struct {
+	dev uint64
+	ino uint64
+}
+
+// getFileID is not supported on windows, callers fall back to comparing resolved paths
+func getFileID(fi os.FileInfo) (fileID, bool) {
+	return fileID{}, false
+}
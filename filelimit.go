@@ -0,0 +1,103 @@
+package mres
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"time"
+)
+
+type (
+	// FileLimits keep a single pathological file from stalling the scan, set them with Scanner.SetFileLimits.
+	// Zero values mean no limit. The files skipped or aborted are reported as errors wrapping
+	// ErrFileTooLarge and ErrFileTimeout, the file expressions still apply to them.
+	FileLimits struct {
+		// MaxSize is the maximum size in bytes of a file whose content is scanned, archives included
+		MaxSize int64 `json:"max_size,omitempty"`
+		// Timeout is the maximum time spent scanning the content of a file. It is checked between reads,
+		// the matches found until then are reported.
+		Timeout time.Duration `json:"timeout,omitempty"`
+	}
+
+	// contextFile fails the reads once the deadline of the scan of the file is exceeded
+	contextFile struct {
+		fs.File
+		ctx context.Context
+	}
+
+	// contextReaderAtFile is a contextFile keeping the random access of the file, e.g. for zip archives
+	contextReaderAtFile struct {
+		contextFile
+		ra io.ReaderAt
+	}
+)
+
+// SetFileLimits sets the maximum size of the files whose content is scanned and the time spent on each of them
+func (s *Scanner) SetFileLimits(limits FileLimits) {
+	if limits.MaxSize < 0 {
+		limits.MaxSize = 0
+	}
+	if limits.Timeout < 0 {
+		limits.Timeout = 0
+	}
+	s.fileLimits = limits
+}
+
+// limitedOpener returns the opener of the files of a job, failing with ErrFileTooLarge for files larger than
+// FileLimits.MaxSize and whose reads fail once ctx is done. It returns open if there are no file limits.
+func (s *Scanner) limitedOpener(ctx context.Context, open fileOpener) fileOpener {
+	if s.fileLimits.MaxSize == 0 && s.fileLimits.Timeout == 0 {
+		return open
+	}
+	return func(job scanJob) (fs.File, error) {
+		fp, err := open(job)
+		if err != nil {
+			return nil, err
+		}
+		if s.fileLimits.MaxSize > 0 {
+			if fi, err := fp.Stat(); err == nil && fi.Size() > s.fileLimits.MaxSize {
+				fp.Close()
+				return nil, fmt.Errorf("%w: file: %s is larger than %d bytes, its content is not scanned", ErrFileTooLarge, job.path, s.fileLimits.MaxSize)
+			}
+		}
+		if s.fileLimits.Timeout == 0 {
+			return fp, nil
+		}
+		if ra, ok := fp.(io.ReaderAt); ok {
+			return &contextReaderAtFile{contextFile: contextFile{File: fp, ctx: ctx}, ra: ra}, nil
+		}
+		return &contextFile{File: fp, ctx: ctx}, nil
+	}
+}
+
+// jobContext returns the context of the scan of a file, with the deadline of FileLimits.Timeout if set
+func (s *Scanner) jobContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.fileLimits.Timeout == 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, s.fileLimits.Timeout)
+}
+
+// timeoutError returns ErrFileTimeout if the scan of the file of the job was aborted by its deadline,
+// err otherwise. A cancelled scan is not a timeout.
+func (s *Scanner) timeoutError(ctx context.Context, jobCtx context.Context, job scanJob, err error) error {
+	if ctx.Err() == nil && jobCtx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("%w: scan of file: %s was aborted after %s", ErrFileTimeout, job.path, s.fileLimits.Timeout)
+	}
+	return err
+}
+
+func (f *contextFile) Read(p []byte) (int, error) {
+	if err := f.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return f.File.Read(p)
+}
+
+func (f *contextReaderAtFile) ReadAt(p []byte, off int64) (int, error) {
+	if err := f.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return f.ra.ReadAt(p, off)
+}
@@ -0,0 +1,85 @@
+package mres
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// slowFS serves the files of a fstest.MapFS a few bytes at a time
+type slowFS struct {
+	fstest.MapFS
+}
+
+type slowFile struct {
+	fs.File
+}
+
+func (s slowFS) Open(name string) (fs.File, error) {
+	f, err := s.MapFS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasSuffix(name, ".txt") {
+		return &slowFile{File: f}, nil
+	}
+	return f, nil
+}
+
+func (f *slowFile) Read(p []byte) (int, error) {
+	time.Sleep(5 * time.Millisecond)
+	if len(p) > 8 {
+		p = p[:8]
+	}
+	return f.File.Read(p)
+}
+
+func TestScanner_SetFileLimits(t *testing.T) {
+	root := newTestTree(t, map[string]string{
+		"small.env": "TOKEN=xorp-1",
+		"large.env": "TOKEN=xorp-2" + strings.Repeat("\n", 100),
+	})
+	defer os.RemoveAll(root)
+	exps := Expressions{
+		FileMatchExps:    []FileMatchExp{{ID: "env", Exp: `\.env$`}},
+		ContentMatchExps: []ContentMatchExp{{ID: "xorp", Exp: `xorp-\w+`}},
+	}
+	s, _ := NewScanner(exps)
+	s.SetFileLimits(FileLimits{MaxSize: 50})
+	results, errs := s.Scan(context.TODO(), []string{root}, 2)
+	if len(errs) != 1 || !errors.Is(errs[0], ErrFileTooLarge) {
+		t.Errorf("Scanner.Scan() errs = %v, want one ErrFileTooLarge", errs)
+	}
+	if len(results.FileMatches) != 2 || len(results.ContentMatches) != 1 || results.ContentMatches[0].MatchString != "xorp-1" {
+		t.Errorf("Scanner.Scan() = %v, want both file matches and the content match of the small file", results)
+	}
+
+	fsys := slowFS{fstest.MapFS{
+		"slow.txt": {Data: []byte(strings.Repeat("xorp-3\n", 1000))},
+		"fast.env": {Data: []byte("xorp-4\n")},
+	}}
+	s.SetFileLimits(FileLimits{Timeout: 50 * time.Millisecond})
+	results, errs = s.ScanFS(context.TODO(), fsys, []string{"."}, 2)
+	if len(errs) != 1 || !errors.Is(errs[0], ErrFileTimeout) {
+		t.Errorf("Scanner.ScanFS() errs = %v, want one ErrFileTimeout", errs)
+	}
+	if n := len(results.ContentMatches); n < 1 || n >= 1001 {
+		t.Errorf("Scanner.ScanFS() = %v content matches, want the fast file and part of the slow one", n)
+	}
+
+	// a cancelled scan is not reported as a timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	s.SetFileLimits(FileLimits{Timeout: time.Minute})
+	_, errs = s.ScanFS(ctx, fsys, []string{"slow.txt"}, 1)
+	for _, err := range errs {
+		if errors.Is(err, ErrFileTimeout) {
+			t.Errorf("Scanner.ScanFS() err = %v, want no ErrFileTimeout", err)
+		}
+	}
+}
@@ -11,12 +11,18 @@ type (
 		Exp string `json:"exp,omitempty"`
 		//FlipMatch since Go doesn't support negative look ahead
 		FlipMatch bool `json:"flip_match,omitempty"`
+		// Examples are the paths the rule is tested with
+		Examples RuleExamples `json:"examples,omitempty"`
+		// Encoding forces the encoding of the files matched by a named file filter, see Expressions.FileFilters
+		Encoding Encoding `json:"encoding,omitempty"`
+		RuleMetadata
 	}
 
 	fileMatcher struct {
 		ID        string
 		Exp       *regexp.Regexp
 		FlipMatch bool
+		rule      *RuleMetadata
 	}
 
 	fileMatchers []fileMatcher
@@ -24,6 +30,14 @@ type (
 	FileMatchResult struct {
 		ExpID    string `json:"exp_id,omitempty"`
 		FilePath string `json:"file_path,omitempty"`
+		// LinkPath is the symlink path the file was reached through when following symlinks
+		LinkPath string `json:"link_path,omitempty"`
+		// Commit is the commit which introduced the content in git history scans
+		Commit *CommitInfo `json:"commit,omitempty"`
+		// Resolved is set by watch scans when a previously reported match is gone
+		Resolved bool `json:"resolved,omitempty"`
+		// Rule is the metadata of the rule, if it has any
+		Rule *RuleMetadata `json:"rule,omitempty"`
 	}
 )
 
@@ -46,7 +60,7 @@ func (matchers fileMatchers) matchAll(filePath string) []FileMatchResult {
 		if !m.match(pathBytes) {
 			continue
 		}
-		results = append(results, FileMatchResult{ExpID: m.ID, FilePath: filePath})
+		results = append(results, FileMatchResult{ExpID: m.ID, FilePath: filePath, Rule: m.rule})
 	}
 	return results
 }
@@ -56,10 +70,14 @@ func newFileMatcher(e FileMatchExp) (fileMatcher, error) {
 	if err != nil {
 		return fileMatcher{}, fmt.Errorf("error: %v while compiling file match exp for id: %s", err, e.ID)
 	}
+	if err := e.RuleMetadata.validate(); err != nil {
+		return fileMatcher{}, fmt.Errorf("error: %v while compiling file match metadata for id: %s", err, e.ID)
+	}
 	matcher := fileMatcher{
 		ID:        e.ID,
 		Exp:       compiledExp,
 		FlipMatch: e.FlipMatch,
+		rule:      e.RuleMetadata.resultRule(),
 	}
 	return matcher, nil
 }
@@ -0,0 +1,403 @@
+package mres
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultGitMaxBlobSize = 100 * 1024 * 1024
+
+	// gitCommitFormat prints a commit header line as \x00sha\x1fauthor\x1fdate, so it cannot be confused with diff lines
+	gitCommitFormat = "--format=%x00%H%x1f%an <%ae>%x1f%aI"
+	gitNullSHA      = "0000000000000000000000000000000000000000"
+	gitEmptyTree    = "4b825dc642cb6eb9a060e54bf8d69288fbee4904"
+)
+
+type (
+	// GitScanOptions controls the scan of the history of a git repository with Scanner.ScanGit.
+	// The repository is read locally with the git command, nothing is fetched.
+	GitScanOptions struct {
+		// Refs are the refs whose reachable blobs are scanned, e.g. main or v1.0. Defaults to all refs.
+		Refs []string `json:"refs,omitempty"`
+		// DiffRange scans only the lines added by the commits in the range, e.g. main..feature,
+		// instead of all the reachable blobs. File expressions are not applied in this mode.
+		DiffRange string `json:"diff_range,omitempty"`
+		// Staged scans the staged content, the index version, of the files changed since HEAD
+		// instead of the history, e.g. in a pre-commit hook. Results do not carry a commit.
+		Staged bool `json:"staged,omitempty"`
+		// MaxBlobSize is the maximum size of a blob to scan in bytes, defaults to 100MB
+		MaxBlobSize int64 `json:"max_blob_size,omitempty"`
+	}
+
+	// CommitInfo describes the commit a result of a git history scan was found in
+	CommitInfo struct {
+		SHA    string    `json:"sha,omitempty"`
+		Author string    `json:"author,omitempty"`
+		Date   time.Time `json:"date"`
+	}
+
+	// gitCatFile reads blobs through a single long running git cat-file --batch process
+	gitCatFile struct {
+		mu      sync.Mutex
+		cmd     *exec.Cmd
+		stdin   io.WriteCloser
+		stdout  *bufio.Reader
+		maxSize int64
+	}
+
+	// blobFile is a blob read into memory
+	blobFile struct {
+		*bytes.Reader
+		info blobInfo
+	}
+
+	blobInfo struct {
+		name string
+		size int64
+	}
+)
+
+// ScanGitWithCallback scans the history of the git repository at repoPath and calls the passed functions
+// when there is any result or errors. Every blob reachable from GitScanOptions.Refs is scanned once, the results
+// carry the path of the blob and the commit which introduced it. See GitScanOptions.DiffRange to scan diffs only.
+// If you want to stop the scan, call cancel on the context passed.
+func (s *Scanner) ScanGitWithCallback(
+	ctx context.Context, repoPath string, opts GitScanOptions, workerCount int,
+	onFileMatchResult func(r FileMatchResult),
+	onContentMatchResult func(r ContentMatchResult),
+	onError func(e error)) {
+	if repoPath == "" {
+		onError(ErrInvalidArgument)
+		return
+	}
+	if opts.DiffRange != "" {
+		s.scanGitDiff(ctx, repoPath, opts.DiffRange, onContentMatchResult, onError)
+		return
+	}
+	if opts.MaxBlobSize <= 0 {
+		opts.MaxBlobSize = defaultGitMaxBlobSize
+	}
+	catFile, err := newGitCatFile(ctx, repoPath, opts.MaxBlobSize)
+	if err != nil {
+		onError(err)
+		return
+	}
+	defer catFile.close()
+	walk := func(workerCount int, jobC chan<- scanJob, errorC chan<- error) {
+		s.walkGitHistory(ctx, repoPath, opts.Refs, jobC, errorC)
+	}
+	if opts.Staged {
+		walk = func(workerCount int, jobC chan<- scanJob, errorC chan<- error) {
+			s.walkGitIndex(ctx, repoPath, jobC, errorC)
+		}
+	}
+	s.scanWithCallback(ctx, workerCount, walk, catFile.open, onFileMatchResult, onContentMatchResult, onError)
+}
+
+// ScanGit is like ScanGitWithCallback but the results and errors are returned in one go in the end
+func (s *Scanner) ScanGit(ctx context.Context, repoPath string, opts GitScanOptions, workerCount int) (MatchResult, []error) {
+	return collectResults(func(
+		onFileMatchResult func(r FileMatchResult),
+		onContentMatchResult func(r ContentMatchResult),
+		onError func(e error)) {
+		s.ScanGitWithCallback(ctx, repoPath, opts, workerCount, onFileMatchResult, onContentMatchResult, onError)
+	})
+}
+
+// walkGitHistory produces a job for every blob reachable from refs, attributed to the oldest commit introducing it
+func (s *Scanner) walkGitHistory(ctx context.Context, repoPath string, refs []string, jobsC chan<- scanJob, errorsC chan<- error) {
+	defer func() {
+		s.logger.Debug("Closing jobs channel")
+		close(jobsC)
+	}()
+	args := []string{"log", gitCommitFormat, "--raw", "--no-abbrev", "--no-renames", "-m", "--reverse"}
+	if len(refs) == 0 {
+		args = append(args, "--all")
+	}
+	args = append(args, "--end-of-options")
+	args = append(args, refs...)
+	args = append(args, "--")
+	seen := make(map[string]struct{})
+	var commit *CommitInfo
+	err := runGit(ctx, repoPath, args, func(line string) bool {
+		if strings.HasPrefix(line, "\x00") {
+			commit = parseGitCommit(line)
+			return true
+		}
+		blob, path, ok := parseGitRawLine(line)
+		if !ok {
+			return true
+		}
+		if _, ok := seen[blob]; ok {
+			return true
+		}
+		seen[blob] = struct{}{}
+		select {
+		case <-ctx.Done():
+			return false
+		case jobsC <- scanJob{path: path, blob: blob, commit: commit}:
+			return true
+		}
+	})
+	if err != nil && ctx.Err() == nil {
+		errorsC <- err
+	}
+}
+
+// walkGitIndex produces a job for the staged blob of every file added, copied, modified or renamed since HEAD
+func (s *Scanner) walkGitIndex(ctx context.Context, repoPath string, jobsC chan<- scanJob, errorsC chan<- error) {
+	defer func() {
+		s.logger.Debug("Closing jobs channel")
+		close(jobsC)
+	}()
+	// the raw diff of the index against HEAD carries the staged blobs, the empty tree is used before the first commit
+	base := "HEAD"
+	if err := runGit(ctx, repoPath, []string{"rev-parse", "--verify", "-q", "HEAD"}, func(string) bool { return true }); err != nil {
+		base = gitEmptyTree
+	}
+	args := []string{"diff-index", "--cached", "--raw", "--no-abbrev", "--no-renames", "--diff-filter=ACMR", base, "--"}
+	err := runGit(ctx, repoPath, args, func(line string) bool {
+		blob, path, ok := parseGitRawLine(line)
+		if !ok {
+			return true
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case jobsC <- scanJob{path: path, blob: blob}:
+			return true
+		}
+	})
+	if err != nil && ctx.Err() == nil {
+		errorsC <- err
+	}
+}
+
+// scanGitDiff matches the lines added by the commits in commitRange
+func (s *Scanner) scanGitDiff(
+	ctx context.Context, repoPath string, commitRange string,
+	onContentMatchResult func(r ContentMatchResult),
+	onError func(e error)) {
+	args := []string{"log", gitCommitFormat, "-p", "-U0", "--no-color", "--no-renames", "--no-ext-diff", "--end-of-options", commitRange, "--"}
+	var commit *CommitInfo
+	path := ""
+	lineNo := 0
+	inHunk := false
+	var matchers contentMatchers
+	var limiter *fileMatchLimiter
+	onContentMatchResult = s.limitRuleMatches(onContentMatchResult)
+	err := runGit(ctx, repoPath, args, func(line string) bool {
+		switch {
+		case strings.HasPrefix(line, "\x00"):
+			commit = parseGitCommit(line)
+			inHunk = false
+		case strings.HasPrefix(line, "diff --git "):
+			path = ""
+			inHunk = false
+		case !inHunk && strings.HasPrefix(line, "+++ "):
+			path = parseGitDiffPath(strings.TrimPrefix(line, "+++ "))
+			// structured rules need the whole file, they do not apply to the lines of a diff
+			matchers, _ = s.contentMatchers.filterApplicable(path).splitStructured()
+			limiter = newFileMatchLimiter(s.matchLimits)
+		case strings.HasPrefix(line, "@@ "):
+			lineNo = parseGitHunkStart(line)
+			inHunk = path != ""
+		case inHunk && strings.HasPrefix(line, "+"):
+			results := matchers.matchLine(path, lineNo, []byte(line[1:]))
+			if limiter != nil {
+				var fileDone, ruleDone bool
+				results, fileDone, ruleDone = limiter.add(results)
+				if ruleDone {
+					matchers = limiter.active(matchers)
+				}
+				if fileDone {
+					matchers = nil
+				}
+			}
+			for _, r := range results {
+				r.Commit = commit
+				onContentMatchResult(r)
+			}
+			lineNo++
+		case inHunk && strings.HasPrefix(line, " "):
+			lineNo++
+		}
+		return ctx.Err() == nil
+	})
+	if err != nil && ctx.Err() == nil {
+		onError(err)
+	}
+}
+
+// runGit runs git in repoPath and calls onLine for every line of its output until it returns false
+func runGit(ctx context.Context, repoPath string, args []string, onLine func(line string) bool) error {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", repoPath, "-c", "core.quotePath=false"}, args...)...)
+	stderr := new(bytes.Buffer)
+	cmd.Stderr = stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("error: %w while running git %s", err, args[0])
+	}
+	reader := bufio.NewReader(stdout)
+	for {
+		line, err := reader.ReadString('\n')
+		if len(line) > 0 && !onLine(strings.TrimSuffix(line, "\n")) {
+			break
+		}
+		if err != nil {
+			break
+		}
+	}
+	// drain so git is not blocked writing when the lines are not needed anymore
+	io.Copy(io.Discard, stdout)
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("error: %w while running git %s: %s", err, args[0], strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// parseGitCommit parses a commit header printed with gitCommitFormat
+func parseGitCommit(line string) *CommitInfo {
+	parts := strings.SplitN(strings.TrimPrefix(line, "\x00"), "\x1f", 3)
+	commit := &CommitInfo{SHA: parts[0]}
+	if len(parts) == 3 {
+		commit.Author = parts[1]
+		commit.Date, _ = time.Parse(time.RFC3339, parts[2])
+	}
+	return commit
+}
+
+// parseGitRawLine parses a line of git log --raw, e.g. ":100644 100644 <old> <new> M\tpath".
+// Only added or modified regular files are returned.
+func parseGitRawLine(line string) (string, string, bool) {
+	if !strings.HasPrefix(line, ":") {
+		return "", "", false
+	}
+	tab := strings.IndexByte(line, '\t')
+	if tab < 0 {
+		return "", "", false
+	}
+	fields := strings.Fields(line[1:tab])
+	if len(fields) < 5 {
+		return "", "", false
+	}
+	newMode, newBlob, status := fields[1], fields[3], fields[4]
+	if status == "D" || newBlob == gitNullSHA || !strings.HasPrefix(newMode, "100") {
+		return "", "", false
+	}
+	return newBlob, unquoteGitPath(line[tab+1:]), true
+}
+
+// parseGitDiffPath parses the path of a +++ diff header, it is empty for deleted files.
+// Git ends the header with a tab when the path has a space.
+func parseGitDiffPath(header string) string {
+	path := unquoteGitPath(strings.TrimSuffix(header, "\t"))
+	if !strings.HasPrefix(path, "b/") {
+		return ""
+	}
+	return strings.TrimPrefix(path, "b/")
+}
+
+// parseGitHunkStart returns the first line number of the new file in a hunk header, e.g. "@@ -1,2 +3,4 @@"
+func parseGitHunkStart(line string) int {
+	plus := strings.Index(line, " +")
+	if plus < 0 {
+		return 0
+	}
+	start := line[plus+2:]
+	if end := strings.IndexAny(start, ", "); end >= 0 {
+		start = start[:end]
+	}
+	lineNo, _ := strconv.Atoi(start)
+	return lineNo
+}
+
+// unquoteGitPath unquotes a path git quoted because of special characters
+func unquoteGitPath(path string) string {
+	if !strings.HasPrefix(path, "\"") {
+		return path
+	}
+	unquoted, err := strconv.Unquote(path)
+	if err != nil {
+		return path
+	}
+	return unquoted
+}
+
+func newGitCatFile(ctx context.Context, repoPath string, maxSize int64) (*gitCatFile, error) {
+	cmd := exec.CommandContext(ctx, "git", "-C", repoPath, "cat-file", "--batch")
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("error: %w while running git cat-file", err)
+	}
+	return &gitCatFile{cmd: cmd, stdin: stdin, stdout: bufio.NewReader(stdout), maxSize: maxSize}, nil
+}
+
+// open reads the blob of the job into memory
+func (c *gitCatFile) open(job scanJob) (fs.File, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, err := fmt.Fprintln(c.stdin, job.blob); err != nil {
+		return nil, fmt.Errorf("error: %w while reading blob: %s of: %s", err, job.blob, job.path)
+	}
+	header, err := c.stdout.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("error: %w while reading blob: %s of: %s", err, job.blob, job.path)
+	}
+	fields := strings.Fields(header)
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("error: unexpected git cat-file output: %s for blob: %s of: %s", strings.TrimSpace(header), job.blob, job.path)
+	}
+	size, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("error: %w while reading blob: %s of: %s", err, job.blob, job.path)
+	}
+	if size > c.maxSize {
+		// the content and its trailing newline still have to be consumed
+		if _, err := c.stdout.Discard(int(size) + 1); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: blob: %s of: %s is larger than %d bytes", ErrBlobTooLarge, job.blob, job.path, c.maxSize)
+	}
+	content := make([]byte, size+1)
+	if _, err := io.ReadFull(c.stdout, content); err != nil {
+		return nil, fmt.Errorf("error: %w while reading blob: %s of: %s", err, job.blob, job.path)
+	}
+	content = content[:size]
+	return &blobFile{Reader: bytes.NewReader(content), info: blobInfo{name: job.path, size: size}}, nil
+}
+
+func (c *gitCatFile) close() {
+	c.stdin.Close()
+	c.cmd.Wait()
+}
+
+func (b *blobFile) Stat() (fs.FileInfo, error) { return b.info, nil }
+func (b *blobFile) Close() error               { return nil }
+
+func (i blobInfo) Name() string       { return i.name }
+func (i blobInfo) Size() int64        { return i.size }
+func (i blobInfo) Mode() fs.FileMode  { return 0444 }
+func (i blobInfo) ModTime() time.Time { return time.Time{} }
+func (i blobInfo) IsDir() bool        { return false }
+func (i blobInfo) Sys() interface{}   { return nil }
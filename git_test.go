@@ -0,0 +1,190 @@
+package mres
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// newTestGitRepo creates a repository with a commit for every step, a step maps file names to contents
+// and an empty content deletes the file. It returns the repository path and the commit SHAs.
+func newTestGitRepo(t *testing.T, steps []map[string]string) (string, []string) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	root, err := ioutil.TempDir("", "mres-git")
+	if err != nil {
+		t.Fatalf("error while creating temp dir. err: %v", err)
+	}
+	git := func(args ...string) string {
+		cmd := exec.Command("git", append([]string{"-C", root, "-c", "user.name=Tester", "-c", "user.email=tester@example.com"}, args...)...)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("error while running git %v. err: %v, out: %s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	git("init", "-q")
+	shas := make([]string, 0, len(steps))
+	for i, step := range steps {
+		for name, content := range step {
+			path := filepath.Join(root, name)
+			if content == "" {
+				os.Remove(path)
+				continue
+			}
+			os.MkdirAll(filepath.Dir(path), 0755)
+			if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatalf("error while writing file. err: %v", err)
+			}
+		}
+		git("add", "-A")
+		git("commit", "-q", "-m", fmt.Sprintf("step %d", i))
+		shas = append(shas, git("rev-parse", "HEAD"))
+	}
+	return root, shas
+}
+
+func TestScanner_ScanGit(t *testing.T) {
+	repo, shas := newTestGitRepo(t, []map[string]string{
+		{"config.json": "{\n  \"token\": \"xorp-first\"\n}\n", "README.md": "readme\n"},
+		{"config.json": "{\n  \"token\": \"\"\n}\n", "src/app.go": "package app\n\n// xorp-second\n"},
+		{"config.json": "{\n  \"token\": \"xorp-first\"\n}\n", "src/app.go": ""},
+	})
+	defer os.RemoveAll(repo)
+	exps := Expressions{
+		FileMatchExps:    []FileMatchExp{{ID: "go", Exp: `\.go$`}},
+		ContentMatchExps: []ContentMatchExp{{ID: "xorp", Exp: `xorp-\w+`}},
+	}
+	tests := []struct {
+		name      string
+		opts      GitScanOptions
+		wantFiles []string
+		want      []string
+	}{
+		{
+			name:      "all blobs",
+			opts:      GitScanOptions{},
+			wantFiles: []string{"src/app.go@1"},
+			want: []string{
+				"config.json:2:xorp-first@0",
+				"src/app.go:3:xorp-second@1",
+			},
+		},
+		{
+			name:      "blobs of a ref",
+			opts:      GitScanOptions{Refs: []string{shas[0]}},
+			wantFiles: []string{},
+			want:      []string{"config.json:2:xorp-first@0"},
+		},
+		{
+			name:      "diff range",
+			opts:      GitScanOptions{DiffRange: shas[0] + "..HEAD"},
+			wantFiles: []string{},
+			want: []string{
+				"config.json:2:xorp-first@2",
+				"src/app.go:3:xorp-second@1",
+			},
+		},
+	}
+	commitIndex := func(c *CommitInfo) int {
+		for i, sha := range shas {
+			if c != nil && c.SHA == sha {
+				return i
+			}
+		}
+		return -1
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := NewScanner(exps)
+			got, errs := s.ScanGit(context.TODO(), repo, tt.opts, 2)
+			if len(errs) > 0 {
+				t.Fatalf("Scanner.ScanGit() errors = %v", errs)
+			}
+			files := make([]string, 0)
+			for _, r := range got.FileMatches {
+				files = append(files, fmt.Sprintf("%s@%d", r.FilePath, commitIndex(r.Commit)))
+			}
+			matches := make([]string, 0)
+			for _, r := range got.ContentMatches {
+				matches = append(matches, fmt.Sprintf("%s:%d:%s@%d", r.FilePath, r.LineNumber, r.MatchString, commitIndex(r.Commit)))
+				if r.Commit.Author != "Tester <tester@example.com>" || r.Commit.Date.IsZero() {
+					t.Errorf("Scanner.ScanGit() commit = %v, want author and date", r.Commit)
+				}
+			}
+			sort.Strings(files)
+			sort.Strings(matches)
+			if !reflect.DeepEqual(files, tt.wantFiles) {
+				t.Errorf("Scanner.ScanGit() file matches = %v, want %v", files, tt.wantFiles)
+			}
+			if !reflect.DeepEqual(matches, tt.want) {
+				t.Errorf("Scanner.ScanGit() content matches = %v, want %v", matches, tt.want)
+			}
+		})
+	}
+}
+
+func TestScanner_ScanGitStaged(t *testing.T) {
+	repo, _ := newTestGitRepo(t, []map[string]string{
+		{"config.json": "{\n  \"token\": \"xorp-committed\"\n}\n"},
+	})
+	defer os.RemoveAll(repo)
+	write := func(name string, content string) {
+		if err := ioutil.WriteFile(filepath.Join(repo, name), []byte(content), 0644); err != nil {
+			t.Fatalf("error while writing file. err: %v", err)
+		}
+	}
+	// the staged version has the secret, the working tree version does not
+	write("app.env", "TOKEN=xorp-staged\n")
+	if out, err := exec.Command("git", "-C", repo, "add", "app.env").CombinedOutput(); err != nil {
+		t.Fatalf("error while staging. err: %v, out: %s", err, out)
+	}
+	write("app.env", "TOKEN=\n")
+	write("untracked.env", "TOKEN=xorp-untracked\n")
+	s, _ := NewScanner(Expressions{ContentMatchExps: []ContentMatchExp{{ID: "xorp", Exp: `xorp-\w+`}}})
+	got, errs := s.ScanGit(context.TODO(), repo, GitScanOptions{Staged: true}, 1)
+	if len(errs) > 0 {
+		t.Fatalf("Scanner.ScanGit() errors = %v", errs)
+	}
+	if len(got.ContentMatches) != 1 || got.ContentMatches[0].FilePath != "app.env" || got.ContentMatches[0].MatchString != "xorp-staged" {
+		t.Errorf("Scanner.ScanGit() content matches = %v, want xorp-staged in app.env", got.ContentMatches)
+	}
+}
+
+func TestScanner_ScanGitDiffPathWithSpace(t *testing.T) {
+	repo, shas := newTestGitRepo(t, []map[string]string{
+		{"README.md": "readme\n"},
+		{"my file.env": "TOKEN=xorp-spaced\n"},
+	})
+	defer os.RemoveAll(repo)
+	// the rule only applies to .env files, git ends the diff header of the file with a tab
+	s, errs := NewScanner(Expressions{ContentMatchExps: []ContentMatchExp{
+		{ID: "env", Exp: `xorp-\w+`, FileFilterEnabled: true, FileMatchExp: FileMatchExp{Exp: `\.env$`}},
+	}})
+	if len(errs) > 0 {
+		t.Fatalf("NewScanner() errs = %v", errs)
+	}
+	for _, opts := range []GitScanOptions{{}, {DiffRange: shas[0] + "..HEAD"}} {
+		got, scanErrs := s.ScanGit(context.TODO(), repo, opts, 1)
+		if len(scanErrs) > 0 {
+			t.Fatalf("Scanner.ScanGit() errors = %v", scanErrs)
+		}
+		matches := make([]string, 0)
+		for _, r := range got.ContentMatches {
+			matches = append(matches, fmt.Sprintf("%s:%s:%s", r.ExpID, r.FilePath, r.MatchString))
+		}
+		sort.Strings(matches)
+		want := []string{"env:my file.env:xorp-spaced"}
+		if !reflect.DeepEqual(matches, want) {
+			t.Errorf("Scanner.ScanGit() with diff range %q content matches = %q, want %q", opts.DiffRange, matches, want)
+		}
+	}
+}
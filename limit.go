@@ -0,0 +1,118 @@
+package mres
+
+type (
+	// MatchLimits bound the number of content matches, so a minified or generated file cannot produce
+	// millions of results. Set them with Scanner.SetMatchLimits, zero values mean no limit.
+	// The match reaching a limit is reported with Truncated set, except in FirstMatchOnly mode.
+	MatchLimits struct {
+		// MaxPerFile is the maximum number of matches of a file, the rest of the file is not scanned
+		MaxPerFile int `json:"max_per_file,omitempty"`
+		// MaxPerRulePerFile is the maximum number of matches of a rule in a file,
+		// the rule is not evaluated on the rest of the file
+		MaxPerRulePerFile int `json:"max_per_rule_per_file,omitempty"`
+		// MaxPerRule is the maximum number of matches of a rule in a scan, the following ones are dropped
+		MaxPerRule int `json:"max_per_rule,omitempty"`
+		// FirstMatchOnly stops scanning a file at its first match, like grep -l
+		FirstMatchOnly bool `json:"first_match_only,omitempty"`
+	}
+
+	// fileMatchLimiter applies the per file limits to the matches of a file
+	fileMatchLimiter struct {
+		maxPerFile        int
+		maxPerRulePerFile int
+		markTruncated     bool
+		count             int
+		ruleCounts        map[string]int
+		// done is set once the rest of the file must not be scanned
+		done bool
+	}
+)
+
+// SetMatchLimits sets the limits on the number of content matches, negative values are the same as zero
+func (s *Scanner) SetMatchLimits(limits MatchLimits) {
+	if limits.MaxPerFile < 0 {
+		limits.MaxPerFile = 0
+	}
+	if limits.MaxPerRulePerFile < 0 {
+		limits.MaxPerRulePerFile = 0
+	}
+	if limits.MaxPerRule < 0 {
+		limits.MaxPerRule = 0
+	}
+	s.matchLimits = limits
+}
+
+// newFileMatchLimiter returns the limiter of the matches of a file, nil if there is no per file limit
+func newFileMatchLimiter(limits MatchLimits) *fileMatchLimiter {
+	l := &fileMatchLimiter{
+		maxPerFile:        limits.MaxPerFile,
+		maxPerRulePerFile: limits.MaxPerRulePerFile,
+		markTruncated:     !limits.FirstMatchOnly,
+		ruleCounts:        make(map[string]int),
+	}
+	if limits.FirstMatchOnly {
+		l.maxPerFile = 1
+	}
+	if l.maxPerFile == 0 && l.maxPerRulePerFile == 0 {
+		return nil
+	}
+	return l
+}
+
+// add returns the matches of a line within the limits. fileDone is set when the rest of the file
+// must not be scanned and ruleDone when a rule reached its limit, see active.
+func (l *fileMatchLimiter) add(results []ContentMatchResult) (kept []ContentMatchResult, fileDone bool, ruleDone bool) {
+	for _, r := range results {
+		if l.ruleExhausted(r.ExpID) {
+			continue
+		}
+		l.count++
+		l.ruleCounts[r.ExpID]++
+		if l.ruleExhausted(r.ExpID) {
+			r.Truncated = l.markTruncated
+			ruleDone = true
+		}
+		if l.maxPerFile > 0 && l.count >= l.maxPerFile {
+			r.Truncated = l.markTruncated
+			l.done = true
+			return append(kept, r), true, ruleDone
+		}
+		kept = append(kept, r)
+	}
+	return kept, false, ruleDone
+}
+
+func (l *fileMatchLimiter) ruleExhausted(expID string) bool {
+	return l.maxPerRulePerFile > 0 && l.ruleCounts[expID] >= l.maxPerRulePerFile
+}
+
+// active returns the matchers whose rule did not reach its limit in the file
+func (l *fileMatchLimiter) active(matchers contentMatchers) contentMatchers {
+	active := make(contentMatchers, 0, len(matchers))
+	for _, m := range matchers {
+		if !l.ruleExhausted(m.ID) {
+			active = append(active, m)
+		}
+	}
+	return active
+}
+
+// limitRuleMatches wraps onContentMatchResult to drop the matches of a rule once MatchLimits.MaxPerRule
+// is reached in the scan. The returned function is not safe for concurrent use.
+func (s *Scanner) limitRuleMatches(onContentMatchResult func(r ContentMatchResult)) func(r ContentMatchResult) {
+	max := s.matchLimits.MaxPerRule
+	if max == 0 {
+		return onContentMatchResult
+	}
+	counts := make(map[string]int)
+	return func(r ContentMatchResult) {
+		if counts[r.ExpID] >= max {
+			return
+		}
+		counts[r.ExpID]++
+		if counts[r.ExpID] == max {
+			r.Truncated = true
+		}
+		onContentMatchResult(r)
+	}
+}
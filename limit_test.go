@@ -0,0 +1,82 @@
+package mres
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestScanner_SetMatchLimits(t *testing.T) {
+	exps := Expressions{ContentMatchExps: []ContentMatchExp{
+		{ID: "a", Exp: `a\d`},
+		{ID: "b", Exp: `b\d`},
+	}}
+	content := "a1 b1 a2\na3 b2\nb3 a4\n"
+	tests := []struct {
+		name          string
+		limits        MatchLimits
+		want          []string
+		wantTruncated []string
+	}{
+		{name: "no limits", want: []string{"a1", "a2", "b1", "a3", "b2", "a4", "b3"}},
+		{name: "per file", limits: MatchLimits{MaxPerFile: 4}, want: []string{"a1", "a2", "b1", "a3"}, wantTruncated: []string{"a3"}},
+		{name: "per rule per file", limits: MatchLimits{MaxPerRulePerFile: 2}, want: []string{"a1", "a2", "b1", "b2"}, wantTruncated: []string{"a2", "b2"}},
+		{name: "per rule", limits: MatchLimits{MaxPerRule: 1}, want: []string{"a1", "b1"}, wantTruncated: []string{"a1", "b1"}},
+		{name: "first match only", limits: MatchLimits{FirstMatchOnly: true}, want: []string{"a1"}},
+		{name: "negative limits", limits: MatchLimits{MaxPerFile: -1, MaxPerRulePerFile: -1, MaxPerRule: -1}, want: []string{"a1", "a2", "b1", "a3", "b2", "a4", "b3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := NewScanner(exps)
+			s.SetMatchLimits(tt.limits)
+			results, err := s.ScanReader(context.TODO(), "-", strings.NewReader(content))
+			if err != nil {
+				t.Fatalf("Scanner.ScanReader() error = %v", err)
+			}
+			got := make([]string, 0)
+			truncated := make([]string, 0)
+			for _, r := range results {
+				got = append(got, r.MatchString)
+				if r.Truncated {
+					truncated = append(truncated, r.MatchString)
+				}
+			}
+			if tt.wantTruncated == nil {
+				tt.wantTruncated = []string{}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Scanner.ScanReader() = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(truncated, tt.wantTruncated) {
+				t.Errorf("Scanner.ScanReader() truncated = %v, want %v", truncated, tt.wantTruncated)
+			}
+		})
+	}
+}
+
+func TestScanner_MatchLimitsScan(t *testing.T) {
+	root := newTestTree(t, map[string]string{
+		"one.txt":   "xorp-1 xorp-2 xorp-3",
+		"two.txt":   "xorp-4 xorp-5 xorp-6",
+		"three.txt": "xorp-7 xorp-8 xorp-9",
+	})
+	defer os.RemoveAll(root)
+	s, _ := NewScanner(Expressions{ContentMatchExps: []ContentMatchExp{{ID: "xorp", Exp: `xorp-\d`}}})
+	s.SetMatchLimits(MatchLimits{MaxPerRulePerFile: 2, MaxPerRule: 5})
+	results, errs := s.Scan(context.TODO(), []string{root}, 2)
+	if len(errs) > 0 {
+		t.Fatalf("Scanner.Scan() errs = %v", errs)
+	}
+	truncated := 0
+	for _, r := range results.ContentMatches {
+		if r.Truncated {
+			truncated++
+		}
+	}
+	// two matches of two files and one of the third, which reaches the limit of the rule
+	if len(results.ContentMatches) != 5 || truncated != 3 {
+		t.Errorf("Scanner.Scan() = %v matches with %v truncated, want 5 with 3 truncated", len(results.ContentMatches), truncated)
+	}
+}
@@ -0,0 +1,139 @@
+package mres
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+)
+
+const (
+	// LineSplitAuto ends the lines at LF, CRLF or a bare CR, it is the default
+	LineSplitAuto LineSplit = "auto"
+	// LineSplitLF ends the lines at LF, a CR before it is dropped
+	LineSplitLF LineSplit = "lf"
+	// LineSplitCRLF ends the lines at CRLF only, bare LF and CR are part of the line
+	LineSplitCRLF LineSplit = "crlf"
+	// LineSplitCR ends the lines at CR only, e.g. for old Mac files
+	LineSplitCR LineSplit = "cr"
+	// LineSplitNUL splits NUL separated records, e.g. the output of find -print0
+	LineSplitNUL LineSplit = "nul"
+
+	// maxEmptyReads is the number of reads without data after which the content is given up, as bufio.Scanner does
+	maxEmptyReads = 100
+)
+
+type (
+	// LineSplit is how the content is split in the lines a content rule is matched with.
+	// The lines are numbered from 1 whatever the split is, so a CRLF file gets the same line numbers
+	// with the auto, lf and crlf splits.
+	LineSplit string
+
+	// splitGroup are the matchers splitting the content the same way, with the state of their split
+	splitGroup struct {
+		key      string
+		split    bufio.SplitFunc
+		matchers contentMatchers
+		// start is the offset of the next line in the buffer
+		start  int
+		lineNo int
+		done   bool
+	}
+)
+
+// newSplitFunc returns the split function of the lines of a content rule and the key identifying it
+func newSplitFunc(split LineSplit, delimiter string) (string, bufio.SplitFunc, error) {
+	if delimiter != "" {
+		if split != "" {
+			return "", nil, fmt.Errorf("%w: line_split and line_delimiter are both set", ErrInvalidArgument)
+		}
+		return "delimiter:" + delimiter, scanDelimited([]byte(delimiter)), nil
+	}
+	switch split {
+	case "", LineSplitAuto:
+		return string(LineSplitAuto), scanAnyLines, nil
+	case LineSplitLF:
+		return string(split), bufio.ScanLines, nil
+	case LineSplitCRLF:
+		return string(split), scanDelimited([]byte("\r\n")), nil
+	case LineSplitCR:
+		return string(split), scanDelimited([]byte("\r")), nil
+	case LineSplitNUL:
+		return string(split), scanDelimited([]byte{0}), nil
+	}
+	return "", nil, fmt.Errorf("%w: unknown line split: %s", ErrInvalidArgument, split)
+}
+
+// scanAnyLines is a bufio.SplitFunc ending the lines at LF, CRLF or a bare CR
+func scanAnyLines(data []byte, atEOF bool) (int, []byte, error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		switch {
+		case data[i] == '\n':
+			return i + 1, data[:i], nil
+		case i+1 < len(data) && data[i+1] == '\n':
+			return i + 2, data[:i], nil
+		case i+1 < len(data) || atEOF:
+			return i + 1, data[:i], nil
+		}
+		// a CR at the end of the data, a LF may follow
+		return 0, nil, nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// scanDelimited returns a bufio.SplitFunc ending the lines at the delimiter
+func scanDelimited(delimiter []byte) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		if atEOF && len(data) == 0 {
+			return 0, nil, nil
+		}
+		if i := bytes.Index(data, delimiter); i >= 0 {
+			return i + len(delimiter), data[:i], nil
+		}
+		if atEOF {
+			return len(data), data, nil
+		}
+		return 0, nil, nil
+	}
+}
+
+// splitGroups groups the matchers by their split, in the order of their first matcher
+func (matchers contentMatchers) splitGroups() []*splitGroup {
+	groups := make([]*splitGroup, 0, 1)
+	for _, m := range matchers {
+		var group *splitGroup
+		for _, g := range groups {
+			if g.key == m.splitKey {
+				group = g
+				break
+			}
+		}
+		if group == nil {
+			group = &splitGroup{key: m.splitKey, split: m.split}
+			groups = append(groups, group)
+		}
+		group.matchers = append(group.matchers, m)
+	}
+	return groups
+}
+
+// nextLine returns the next line of the group in data, the content of the buffer from the start of the group,
+// and false if more data is needed. The group is done once the content is over.
+func (g *splitGroup) nextLine(data []byte, atEOF bool) ([]byte, bool, error) {
+	advance, line, err := g.split(data, atEOF)
+	if err != nil {
+		return nil, false, err
+	}
+	if advance == 0 && line == nil {
+		g.done = atEOF
+		return nil, false, nil
+	}
+	g.start += advance
+	g.lineNo++
+	return line, true, nil
+}
@@ -0,0 +1,90 @@
+package mres
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestScanner_ScanReaderLineSplit(t *testing.T) {
+	tests := []struct {
+		name      string
+		split     LineSplit
+		delimiter string
+		content   string
+		want      []string
+	}{
+		{name: "auto lf", content: "a\nxorp-1\n", want: []string{"2:xorp-1"}},
+		{name: "auto crlf", content: "a\r\nxorp-1\r\n", want: []string{"2:xorp-1"}},
+		{name: "auto cr", content: "a\rb\rxorp-1", want: []string{"3:xorp-1"}},
+		{name: "auto mixed", content: "a\r\nb\rc\nxorp-1\r\rxorp-2", want: []string{"4:xorp-1", "6:xorp-2"}},
+		{name: "lf crlf", split: LineSplitLF, content: "a\r\nxorp-1\r\n", want: []string{"2:xorp-1"}},
+		{name: "lf cr", split: LineSplitLF, content: "a\rxorp-1\n", want: []string{"1:xorp-1"}},
+		{name: "crlf", split: LineSplitCRLF, content: "a\nb\r\nxorp-1\r\n", want: []string{"2:xorp-1"}},
+		{name: "cr", split: LineSplitCR, content: "a\rb\nc\rxorp-1", want: []string{"3:xorp-1"}},
+		{name: "nul", split: LineSplitNUL, content: "a\nb\x00xorp-1\x00xorp-2", want: []string{"2:xorp-1", "3:xorp-2"}},
+		{name: "delimiter", delimiter: "--", content: "a\nb--xorp-1\nc--xorp-2", want: []string{"2:xorp-1", "3:xorp-2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, errs := NewScanner(Expressions{ContentMatchExps: []ContentMatchExp{{ID: "xorp", Exp: `xorp-\d+`, LineSplit: tt.split, LineDelimiter: tt.delimiter}}})
+			if len(errs) > 0 {
+				t.Fatalf("NewScanner() errs = %v", errs)
+			}
+			// one byte reads cut the line endings between reads
+			results, err := s.ScanReader(context.TODO(), "-", iotest.OneByteReader(strings.NewReader(tt.content)))
+			if err != nil {
+				t.Fatalf("Scanner.ScanReader() error = %v", err)
+			}
+			got := make([]string, 0)
+			for _, r := range results {
+				got = append(got, fmt.Sprintf("%d:%s", r.LineNumber, r.MatchString))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Scanner.ScanReader() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	for _, e := range []ContentMatchExp{
+		{ID: "unknown", Exp: `x`, LineSplit: "lfcr"},
+		{ID: "both", Exp: `x`, LineSplit: LineSplitNUL, LineDelimiter: ";"},
+	} {
+		if _, errs := NewScanner(Expressions{ContentMatchExps: []ContentMatchExp{e}}); len(errs) == 0 {
+			t.Errorf("NewScanner() with line split of %s errs = nil, want an error", e.ID)
+		}
+	}
+}
+
+func Test_contentMatchers_matchLinesSplitGroups(t *testing.T) {
+	matchers, errs := buildContentMatchers([]ContentMatchExp{
+		{ID: "lf", Exp: `xorp-\d+`, LineSplit: LineSplitLF},
+		{ID: "nul", Exp: `xorp-\d+`, LineSplit: LineSplitNUL},
+		{ID: "auto", Exp: `xorp-\d+`},
+	}, nil)
+	if len(errs) > 0 {
+		t.Fatalf("buildContentMatchers() errs = %v", errs)
+	}
+	content := "a\rxorp-1\n" + strings.Repeat("b", 20) + "\x00xorp-2\n"
+	// the buffer is smaller than the content, the groups share it while they are at different offsets
+	results, err := matchers.matchLines("-", iotest.HalfReader(strings.NewReader(content)), make([]byte, 0, 32), nil)
+	if err != nil {
+		t.Fatalf("contentMatchers.matchLines() error = %v", err)
+	}
+	got := make(map[string][]int)
+	for _, r := range results {
+		got[r.ExpID] = append(got[r.ExpID], r.LineNumber)
+	}
+	want := map[string][]int{"lf": {1, 2}, "nul": {1, 2}, "auto": {2, 3}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("contentMatchers.matchLines() lines = %v, want %v", got, want)
+	}
+	_, err = matchers.matchLines("-", strings.NewReader(strings.Repeat("c", 40)+"\n"), make([]byte, 0, 32), nil)
+	if !errors.Is(err, bufio.ErrTooLong) {
+		t.Errorf("contentMatchers.matchLines() with a long line error = %v, want %v", err, bufio.ErrTooLong)
+	}
+}
@@ -0,0 +1,217 @@
+package mres
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+)
+
+const (
+	// LintError issues make NewScanner fail
+	LintError LintLevel = "error"
+	// LintWarning issues are kept on the scanner, see Scanner.LintWarnings
+	LintWarning LintLevel = "warning"
+)
+
+// unescapedExtension finds extension filters like .go$ whose dot matches any character, e.g. in "xgo"
+var unescapedExtension = regexp.MustCompile(`(^|[^\\])\.[A-Za-z0-9]{1,8}(\$|\||\)|$)`)
+
+type (
+	// LintLevel is how bad a lint issue is
+	LintLevel string
+
+	// LintIssue is a problem of a rule found by LintExpressions
+	LintIssue struct {
+		Level LintLevel
+		ExpID string
+		// Rule is the position of the rule in the config, e.g. content_match_exps[2]
+		Rule string
+		// Line is the line of the rule in the config file, only set by LintConfig
+		Line    int
+		Message string
+	}
+)
+
+func (i *LintIssue) Error() string {
+	position := i.Rule
+	if i.Line > 0 {
+		position = fmt.Sprintf("line %d: %s", i.Line, i.Rule)
+	}
+	return fmt.Sprintf("%s: %s (id: %q): %s", i.Level, position, i.ExpID, i.Message)
+}
+
+// LintExpressions checks the rules for mistakes leading to confusing results: empty and duplicate ids,
+// empty and always matching expressions, file filters which are never applied or match everything and
+// extension filters with an unescaped dot. Expressions which do not compile and references to unknown
+// named file filters are left to NewScanner.
+func LintExpressions(exps Expressions) []*LintIssue {
+	issues := make([]*LintIssue, 0)
+	add := func(level LintLevel, rule string, id string, format string, args ...interface{}) {
+		issues = append(issues, &LintIssue{Level: level, ExpID: id, Rule: rule, Message: fmt.Sprintf(format, args...)})
+	}
+	fileIDs := make(map[string]string)
+	for i, e := range exps.FileMatchExps {
+		rule := fmt.Sprintf("file_match_exps[%d]", i)
+		lintID(add, fileIDs, rule, e.ID)
+		if e.Exp == "" {
+			add(LintError, rule, e.ID, "empty exp matches every path")
+			continue
+		}
+		if matchesEmpty(e.Exp) {
+			add(LintWarning, rule, e.ID, "exp matches the empty string so it matches every path")
+		}
+		if unescapedExtension.MatchString(e.Exp) {
+			add(LintWarning, rule, e.ID, "exp has an unescaped dot before an extension, use \\. to match a literal dot")
+		}
+		if e.Encoding != "" {
+			add(LintWarning, rule, e.ID, "encoding is ignored, it only applies to named file filters")
+		}
+	}
+	filterIDs := make(map[string]string)
+	usedFilters := make(map[string]bool)
+	for _, e := range exps.ContentMatchExps {
+		usedFilters[e.FileFilter] = true
+	}
+	for i, e := range exps.FileFilters {
+		rule := fmt.Sprintf("file_filters[%d]", i)
+		lintID(add, filterIDs, rule, e.ID)
+		if e.ID != "" && !usedFilters[e.ID] && e.Encoding == "" {
+			add(LintWarning, rule, e.ID, "file filter is not used by any content rule")
+		}
+		switch {
+		case e.Exp == "":
+			add(LintError, rule, e.ID, "empty exp matches every path")
+			continue
+		case matchesEmpty(e.Exp) && !e.FlipMatch:
+			add(LintWarning, rule, e.ID, "exp matches the empty string so it matches every path")
+		}
+		if unescapedExtension.MatchString(e.Exp) {
+			add(LintWarning, rule, e.ID, "exp has an unescaped dot before an extension, use \\. to match a literal dot")
+		}
+	}
+	contentIDs := make(map[string]string)
+	for i, e := range exps.ContentMatchExps {
+		rule := fmt.Sprintf("content_match_exps[%d]", i)
+		lintID(add, contentIDs, rule, e.ID)
+		unit := "line"
+		if e.KeyExp != "" {
+			unit = "value"
+		}
+		switch {
+		case e.Exp == "":
+			add(LintError, rule, e.ID, "empty exp matches every %s", unit)
+		case matchesEmpty(e.Exp):
+			add(LintWarning, rule, e.ID, "exp matches the empty string so every %s gets empty matches", unit)
+		}
+		if e.KeyExp != "" && (e.LineSplit != "" || e.LineDelimiter != "") {
+			add(LintWarning, rule, e.ID, "line split is ignored, structured rules match the values of the files")
+		}
+		filterExp := e.FileMatchExp.Exp
+		switch {
+		case e.FileFilterEnabled && filterExp == "":
+			add(LintError, rule, e.ID, "file filter is enabled without an exp")
+		case !e.FileFilterEnabled && filterExp != "":
+			add(LintWarning, rule, e.ID, "file filter exp is never applied because file_filter_enabled is not set")
+		case e.FileFilterEnabled && matchesEmpty(filterExp) && !e.FileMatchExp.FlipMatch:
+			add(LintWarning, rule, e.ID, "file filter exp matches every path")
+		}
+		if e.FileMatchExp.Encoding != "" {
+			add(LintWarning, rule, e.ID, "file filter encoding is ignored, it only applies to named file filters")
+		}
+		if filterExp != "" && unescapedExtension.MatchString(filterExp) {
+			add(LintWarning, rule, e.ID, "file filter exp has an unescaped dot before an extension, use \\. to match a literal dot")
+		}
+	}
+	return issues
+}
+
+// lintID reports empty ids and ids already used by another rule of the same kind
+func lintID(add func(level LintLevel, rule string, id string, format string, args ...interface{}), seen map[string]string, rule string, id string) {
+	if id == "" {
+		add(LintError, rule, id, "empty id, the results of the rule cannot be told apart")
+		return
+	}
+	if first, ok := seen[id]; ok {
+		add(LintError, rule, id, "duplicate id, already used by %s", first)
+		return
+	}
+	seen[id] = rule
+}
+
+// matchesEmpty reports if the expression compiles and matches the empty string
+func matchesEmpty(exp string) bool {
+	compiled, err := regexp.Compile(exp)
+	return err == nil && compiled.MatchString("")
+}
+
+// LintConfig lints the expressions of the config file at path and sets the line of the rules in the issues.
+// When the config includes other config files, the positions are the ones of the resolved rules and no lines are set.
+func LintConfig(path string) ([]*LintIssue, error) {
+	exps, err := LoadExpressions(path)
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	issues := LintExpressions(exps)
+	config := configFile{}
+	if err := json.Unmarshal(content, &config); err != nil || len(config.Include) > 0 {
+		return issues, nil
+	}
+	lines := configRuleLines(content)
+	for _, issue := range issues {
+		issue.Line = lines[issue.Rule]
+	}
+	return issues, nil
+}
+
+// configRuleLines returns the line of every rule of the JSON config by its position, e.g. content_match_exps[2]
+func configRuleLines(content []byte) map[string]int {
+	lines := make(map[string]int)
+	dec := json.NewDecoder(bytes.NewReader(content))
+	if t, err := dec.Token(); err != nil || t != json.Delim('{') {
+		return lines
+	}
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return lines
+		}
+		name, _ := key.(string)
+		if name != "file_match_exps" && name != "content_match_exps" && name != "file_filters" {
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return lines
+			}
+			continue
+		}
+		if t, err := dec.Token(); err != nil || t != json.Delim('[') {
+			return lines
+		}
+		for i := 0; dec.More(); i++ {
+			// the offset is before the separator and the whitespace preceding the rule
+			offset := int(dec.InputOffset())
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return lines
+			}
+			start := offset + bytes.IndexFunc(content[offset:], func(r rune) bool {
+				return r != ',' && r != ' ' && r != '\t' && r != '\n' && r != '\r'
+			})
+			lines[fmt.Sprintf("%s[%d]", name, i)] = bytes.Count(content[:start], []byte("\n")) + 1
+		}
+		if _, err := dec.Token(); err != nil {
+			return lines
+		}
+	}
+	return lines
+}
+
+// LintWarnings returns the lint warnings of the expressions of the scanner, the lint errors are returned by NewScanner
+func (s *Scanner) LintWarnings() []*LintIssue {
+	return s.lintWarnings
+}
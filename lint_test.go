@@ -0,0 +1,113 @@
+package mres
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLintExpressions(t *testing.T) {
+	exps := Expressions{
+		FileMatchExps: []FileMatchExp{
+			{ID: "go", Exp: `\.go$`},
+			{ID: "go", Exp: `.go$`},
+			{ID: "", Exp: `\.txt$`},
+			{ID: "all", Exp: `.*`},
+			{ID: "empty", Exp: ``},
+		},
+		ContentMatchExps: []ContentMatchExp{
+			{ID: "go", Exp: `xorp-\w+`, FileFilterEnabled: true, FileMatchExp: FileMatchExp{Exp: `(?i).go|.txt`}},
+			{ID: "every-line", Exp: ``},
+			{ID: "optional", Exp: `(xorp)?`},
+			{ID: "no-filter", Exp: `x`, FileFilterEnabled: true},
+			{ID: "unused-filter", Exp: `x`, FileMatchExp: FileMatchExp{Exp: `\.go$`}},
+			{ID: "all-files", Exp: `x`, FileFilterEnabled: true, FileMatchExp: FileMatchExp{Exp: `^`}},
+			{ID: "no-files", Exp: `x`, FileFilterEnabled: true, FileMatchExp: FileMatchExp{Exp: `^`, FlipMatch: true}},
+			{ID: "every-line", Exp: `y`},
+			{ID: "source", Exp: `z`, FileFilter: "source"},
+			{ID: "every-value", Exp: `(xorp)?`, KeyExp: `token`, LineSplit: LineSplitNUL},
+		},
+		FileFilters: []FileMatchExp{
+			{ID: "source", Exp: `\.go$`},
+			{ID: "unused", Exp: `.txt$`},
+			{ID: "source", Exp: `^`},
+		},
+	}
+	want := []string{
+		`warning: file_match_exps[1] (id: "go"): exp has an unescaped dot before an extension, use \. to match a literal dot`,
+		`error: file_match_exps[1] (id: "go"): duplicate id, already used by file_match_exps[0]`,
+		`error: file_match_exps[2] (id: ""): empty id, the results of the rule cannot be told apart`,
+		`warning: file_match_exps[3] (id: "all"): exp matches the empty string so it matches every path`,
+		`error: file_match_exps[4] (id: "empty"): empty exp matches every path`,
+		`warning: content_match_exps[0] (id: "go"): file filter exp has an unescaped dot before an extension, use \. to match a literal dot`,
+		`error: content_match_exps[1] (id: "every-line"): empty exp matches every line`,
+		`warning: content_match_exps[2] (id: "optional"): exp matches the empty string so every line gets empty matches`,
+		`error: content_match_exps[3] (id: "no-filter"): file filter is enabled without an exp`,
+		`warning: content_match_exps[4] (id: "unused-filter"): file filter exp is never applied because file_filter_enabled is not set`,
+		`warning: content_match_exps[5] (id: "all-files"): file filter exp matches every path`,
+		`error: content_match_exps[7] (id: "every-line"): duplicate id, already used by content_match_exps[1]`,
+		`warning: content_match_exps[9] (id: "every-value"): exp matches the empty string so every value gets empty matches`,
+		`warning: content_match_exps[9] (id: "every-value"): line split is ignored, structured rules match the values of the files`,
+		`warning: file_filters[1] (id: "unused"): file filter is not used by any content rule`,
+		`warning: file_filters[1] (id: "unused"): exp has an unescaped dot before an extension, use \. to match a literal dot`,
+		`error: file_filters[2] (id: "source"): duplicate id, already used by file_filters[0]`,
+		`warning: file_filters[2] (id: "source"): exp matches the empty string so it matches every path`,
+	}
+	got := make([]string, 0)
+	for _, issue := range LintExpressions(exps) {
+		got = append(got, issue.Error())
+	}
+	// the order of the issues of a rule does not matter
+	if len(got) != len(want) {
+		t.Fatalf("LintExpressions() = %v, want %v", got, want)
+	}
+	gotSet := make(map[string]bool)
+	for _, g := range got {
+		gotSet[g] = true
+	}
+	for _, w := range want {
+		if !gotSet[w] {
+			t.Errorf("LintExpressions() = %v, missing %v", got, w)
+		}
+	}
+	s, errs := NewScanner(exps)
+	if s != nil || len(errs) != 7 {
+		t.Errorf("NewScanner() errors = %v, want the 7 lint errors", errs)
+	}
+	s, errs = NewScanner(Expressions{FileMatchExps: []FileMatchExp{{ID: "go", Exp: `.go$`}}})
+	if len(errs) > 0 || len(s.LintWarnings()) != 1 {
+		t.Errorf("NewScanner() = %v, %v, want 1 lint warning", s, errs)
+	}
+}
+
+func TestLintConfig(t *testing.T) {
+	root := newTestTree(t, map[string]string{
+		"rules.json": `{
+  "file_match_exps": [
+    {"id": "go", "exp": "\\.go$"}, {"id": "go", "exp": "\\.go$"}
+  ],
+  "content_match_exps": [
+    {"id": "a", "exp": "a"},
+
+    {
+      "id": "",
+      "exp": "b"
+    }
+  ]
+}`,
+	})
+	defer os.RemoveAll(root)
+	issues, err := LintConfig(filepath.Join(root, "rules.json"))
+	if err != nil {
+		t.Fatalf("LintConfig() error = %v", err)
+	}
+	got := make(map[string]int)
+	for _, issue := range issues {
+		got[issue.Rule] = issue.Line
+	}
+	want := map[string]int{"file_match_exps[1]": 3, "content_match_exps[1]": 8}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("LintConfig() lines = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,106 @@
+package mres
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// RedactNone reports the matches as they are
+	RedactNone RedactMode = ""
+	// RedactMask replaces the characters of the match with *, except Redaction.KeepFirst and Redaction.KeepLast
+	RedactMask RedactMode = "mask"
+	// RedactHash replaces the match with its SHA-256, so the same secret can still be recognized
+	RedactHash RedactMode = "hash"
+
+	redactMaskChar = "*"
+)
+
+type (
+	// RedactMode is how a match is redacted
+	RedactMode string
+
+	// Redaction controls how the matches of a content rule are redacted before they are reported,
+	// so the secrets do not end up in logs and result files
+	Redaction struct {
+		Mode RedactMode `json:"mode,omitempty"`
+		// KeepFirst and KeepLast are the number of characters kept at the start and the end of a masked match.
+		// The whole match is masked if it is not longer than both.
+		KeepFirst int `json:"keep_first,omitempty"`
+		KeepLast  int `json:"keep_last,omitempty"`
+		// Group is the name or the index of the capture group to redact, the rest of the match is kept.
+		// The whole match is redacted if it is not set.
+		Group string `json:"group,omitempty"`
+	}
+
+	// redactor is a compiled Redaction
+	redactor struct {
+		opts  Redaction
+		group int
+	}
+)
+
+// newRedactor validates the redaction of the content rule, it returns nil if the matches are not redacted
+func newRedactor(r Redaction, exp *regexp.Regexp) (*redactor, error) {
+	switch r.Mode {
+	case RedactNone:
+		return nil, nil
+	case RedactMask, RedactHash:
+	default:
+		return nil, fmt.Errorf("%w: unknown redaction mode: %s", ErrInvalidArgument, r.Mode)
+	}
+	if r.KeepFirst < 0 || r.KeepLast < 0 {
+		return nil, fmt.Errorf("%w: negative redaction keep_first or keep_last", ErrInvalidArgument)
+	}
+	group, err := subexpIndex(exp, r.Group)
+	if err != nil {
+		return nil, err
+	}
+	return &redactor{opts: r, group: group}, nil
+}
+
+// subexpIndex returns the index of the capture group of exp with the name or the index, 0 if group is not set
+func subexpIndex(exp *regexp.Regexp, group string) (int, error) {
+	if group == "" {
+		return 0, nil
+	}
+	index := exp.SubexpIndex(group)
+	if i, err := strconv.Atoi(group); err == nil {
+		index = i
+	}
+	if index <= 0 || index > exp.NumSubexp() {
+		return 0, fmt.Errorf("%w: unknown capture group: %s", ErrInvalidArgument, group)
+	}
+	return index, nil
+}
+
+// redactMatch returns the redacted match of content at loc, the submatch indexes of the match
+func (red *redactor) redactMatch(content []byte, loc []int) string {
+	start, end := loc[0], loc[1]
+	if red.group == 0 {
+		return red.redact(string(content[start:end]))
+	}
+	groupStart, groupEnd := loc[2*red.group], loc[2*red.group+1]
+	// the group did not participate in the match
+	if groupStart < 0 {
+		return string(content[start:end])
+	}
+	return string(content[start:groupStart]) + red.redact(string(content[groupStart:groupEnd])) + string(content[groupEnd:end])
+}
+
+func (red *redactor) redact(secret string) string {
+	if red.opts.Mode == RedactHash {
+		sum := sha256.Sum256([]byte(secret))
+		return "sha256:" + hex.EncodeToString(sum[:])
+	}
+	runes := []rune(secret)
+	keepFirst, keepLast := red.opts.KeepFirst, red.opts.KeepLast
+	if keepFirst+keepLast >= len(runes) {
+		keepFirst, keepLast = 0, 0
+	}
+	return string(runes[:keepFirst]) + strings.Repeat(redactMaskChar, len(runes)-keepFirst-keepLast) + string(runes[len(runes)-keepLast:])
+}
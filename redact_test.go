@@ -0,0 +1,106 @@
+package mres
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestScanner_ScanReaderRedaction(t *testing.T) {
+	content := "token: \"xorp-1234567890\"\nother: \"xorp-ab\"\n"
+	tests := []struct {
+		name    string
+		exp     string
+		redact  Redaction
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:   "not redacted",
+			exp:    `xorp-\w+`,
+			redact: Redaction{},
+			want:   []string{"xorp-1234567890", "xorp-ab"},
+		},
+		{
+			name:   "full mask",
+			exp:    `xorp-\w+`,
+			redact: Redaction{Mode: RedactMask},
+			want:   []string{"***************", "*******"},
+		},
+		{
+			name:   "keep first and last",
+			exp:    `xorp-\w+`,
+			redact: Redaction{Mode: RedactMask, KeepFirst: 5, KeepLast: 2},
+			want:   []string{"xorp-********90", "*******"},
+		},
+		{
+			name:   "hash",
+			exp:    `xorp-\w+`,
+			redact: Redaction{Mode: RedactHash},
+			want: []string{
+				"sha256:" + strings.Repeat("?", 64),
+				"sha256:" + strings.Repeat("?", 64),
+			},
+		},
+		{
+			name:   "named group",
+			exp:    `token: "(?P<secret>xorp-\w+)"`,
+			redact: Redaction{Mode: RedactMask, KeepFirst: 5, Group: "secret"},
+			want:   []string{`token: "xorp-**********"`},
+		},
+		{
+			name:   "group index",
+			exp:    `(\w+): "(xorp-\w+)"`,
+			redact: Redaction{Mode: RedactMask, Group: "2"},
+			want:   []string{`token: "***************"`, `other: "*******"`},
+		},
+		{
+			name:    "unknown group",
+			exp:     `xorp-(\w+)`,
+			redact:  Redaction{Mode: RedactMask, Group: "secret"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown mode",
+			exp:     `xorp-\w+`,
+			redact:  Redaction{Mode: "blur"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, errs := NewScanner(Expressions{ContentMatchExps: []ContentMatchExp{{ID: "xorp", Exp: tt.exp, Redact: tt.redact}}})
+			if (len(errs) > 0) != tt.wantErr {
+				t.Fatalf("NewScanner() errors = %v, wantErr %v", errs, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			got, err := s.ScanReader(context.TODO(), "config.yaml", strings.NewReader(content))
+			if err != nil {
+				t.Fatalf("Scanner.ScanReader() error = %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Scanner.ScanReader() = %v, want %v", got, tt.want)
+			}
+			for i, r := range got {
+				if !matchesPattern(r.MatchString, tt.want[i]) {
+					t.Errorf("Scanner.ScanReader() match = %v, want %v", r.MatchString, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// matchesPattern compares s to pattern where ? matches any single character
+func matchesPattern(s string, pattern string) bool {
+	if len(s) != len(pattern) {
+		return false
+	}
+	for i := range pattern {
+		if pattern[i] != '?' && pattern[i] != s[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,124 @@
+package mres
+
+import (
+	"fmt"
+)
+
+const (
+	SeverityInfo     Severity = "info"
+	SeverityLow      Severity = "low"
+	SeverityMedium   Severity = "medium"
+	SeverityHigh     Severity = "high"
+	SeverityCritical Severity = "critical"
+
+	ConfidenceLow    Confidence = "low"
+	ConfidenceMedium Confidence = "medium"
+	ConfidenceHigh   Confidence = "high"
+)
+
+// severityLevels orders the severities, unknown severities are 0
+var severityLevels = map[Severity]int{
+	SeverityInfo:     1,
+	SeverityLow:      2,
+	SeverityMedium:   3,
+	SeverityHigh:     4,
+	SeverityCritical: 5,
+}
+
+type (
+	// Severity is how much a match of a rule matters: info, low, medium, high or critical
+	Severity string
+
+	// Confidence is how likely a match of a rule is a true positive: low, medium or high
+	Confidence string
+
+	// RuleMetadata describes a rule for the reports, it is embedded in FileMatchExp and ContentMatchExp
+	// and the results of the rule point to it
+	RuleMetadata struct {
+		Name        string     `json:"name,omitempty"`
+		Severity    Severity   `json:"severity,omitempty"`
+		Confidence  Confidence `json:"confidence,omitempty"`
+		Tags        []string   `json:"tags,omitempty"`
+		Description string     `json:"description,omitempty"`
+		Remediation string     `json:"remediation,omitempty"`
+		References  []string   `json:"references,omitempty"`
+	}
+)
+
+// AtLeast reports if the severity is the same or higher than min
+func (s Severity) AtLeast(min Severity) bool {
+	return severityLevels[s] >= severityLevels[min]
+}
+
+// validate checks the severity and the confidence are known values
+func (m RuleMetadata) validate() error {
+	if _, ok := severityLevels[m.Severity]; m.Severity != "" && !ok {
+		return fmt.Errorf("%w: unknown severity: %s", ErrInvalidArgument, m.Severity)
+	}
+	switch m.Confidence {
+	case "", ConfidenceLow, ConfidenceMedium, ConfidenceHigh:
+	default:
+		return fmt.Errorf("%w: unknown confidence: %s", ErrInvalidArgument, m.Confidence)
+	}
+	return nil
+}
+
+// resultRule returns the metadata the results of the rule point to, nil if the rule has none
+func (m RuleMetadata) resultRule() *RuleMetadata {
+	// empty slices, e.g. "tags": [], are no metadata
+	if m.Name == "" && m.Severity == "" && m.Confidence == "" && len(m.Tags) == 0 && m.Description == "" &&
+		m.Remediation == "" && len(m.References) == 0 {
+		return nil
+	}
+	return &m
+}
+
+// hasAnyTag reports if the rule has one of the tags
+func (m RuleMetadata) hasAnyTag(tags []string) bool {
+	for _, tag := range tags {
+		for _, t := range m.Tags {
+			if t == tag {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Filter returns the rules having one of the tags and at least the minimum severity.
+// Empty tags or minimum severity do not filter, rules without a severity are dropped by a minimum severity.
+// The named file filters are kept if a kept content rule refers to them or if they force an encoding.
+func (e Expressions) Filter(tags []string, minSeverity Severity) Expressions {
+	keep := func(m RuleMetadata) bool {
+		if len(tags) > 0 && !m.hasAnyTag(tags) {
+			return false
+		}
+		return minSeverity == "" || m.Severity != "" && m.Severity.AtLeast(minSeverity)
+	}
+	filtered := Expressions{}
+	for _, exp := range e.FileMatchExps {
+		if keep(exp.RuleMetadata) {
+			filtered.FileMatchExps = append(filtered.FileMatchExps, exp)
+		}
+	}
+	for _, exp := range e.ContentMatchExps {
+		if keep(exp.RuleMetadata) {
+			filtered.ContentMatchExps = append(filtered.ContentMatchExps, exp)
+		}
+	}
+	// only the named file filters of the kept content rules are kept, the others would be unused
+	// unless they force the encoding of the files they match
+	for _, filter := range e.FileFilters {
+		if filter.Encoding != "" {
+			filtered.FileFilters = append(filtered.FileFilters, filter)
+			continue
+		}
+		for _, exp := range filtered.ContentMatchExps {
+			if exp.FileFilter == filter.ID {
+				filtered.FileFilters = append(filtered.FileFilters, filter)
+				break
+			}
+		}
+	}
+	return filtered
+}
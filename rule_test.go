@@ -0,0 +1,91 @@
+package mres
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExpressions_Filter(t *testing.T) {
+	exps := Expressions{
+		FileMatchExps: []FileMatchExp{
+			{ID: "pem", Exp: `\.pem$`, RuleMetadata: RuleMetadata{Severity: SeverityHigh, Tags: []string{"keys"}}},
+			{ID: "todo", Exp: `TODO`},
+		},
+		ContentMatchExps: []ContentMatchExp{
+			{ID: "aws", Exp: `AKIA\w{16}`, RuleMetadata: RuleMetadata{Severity: SeverityCritical, Tags: []string{"cloud", "keys"}}},
+			{ID: "email", Exp: `\w+@\w+\.com`, FileFilter: "mail", RuleMetadata: RuleMetadata{Severity: SeverityLow, Tags: []string{"pii"}}},
+		},
+		FileFilters: []FileMatchExp{
+			{ID: "mail", Exp: `\.eml$`},
+			{ID: "legacy-ini", Exp: `\.ini$`, Encoding: EncodingUTF16LE},
+		},
+	}
+	ids := func(e Expressions) []string {
+		list := make([]string, 0)
+		for _, exp := range e.FileMatchExps {
+			list = append(list, exp.ID)
+		}
+		for _, exp := range e.ContentMatchExps {
+			list = append(list, exp.ID)
+		}
+		for _, filter := range e.FileFilters {
+			list = append(list, "filter:"+filter.ID)
+		}
+		return list
+	}
+	tests := []struct {
+		name        string
+		tags        []string
+		minSeverity Severity
+		want        []string
+	}{
+		{name: "no filter", want: []string{"pem", "todo", "aws", "email", "filter:mail", "filter:legacy-ini"}},
+		{name: "tag", tags: []string{"keys"}, want: []string{"pem", "aws", "filter:legacy-ini"}},
+		{name: "any tag", tags: []string{"pii", "cloud"}, want: []string{"aws", "email", "filter:mail", "filter:legacy-ini"}},
+		{name: "min severity", minSeverity: SeverityHigh, want: []string{"pem", "aws", "filter:legacy-ini"}},
+		{name: "tag and min severity", tags: []string{"keys"}, minSeverity: SeverityCritical, want: []string{"aws", "filter:legacy-ini"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ids(exps.Filter(tt.tags, tt.minSeverity)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expressions.Filter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScanner_RuleMetadata(t *testing.T) {
+	meta := RuleMetadata{
+		Name:        "Xorp token",
+		Severity:    SeverityHigh,
+		Confidence:  ConfidenceMedium,
+		Tags:        []string{"tokens"},
+		Description: "Xorp API token",
+		Remediation: "Revoke the token",
+		References:  []string{"https://example.com/xorp"},
+	}
+	s, errs := NewScanner(Expressions{ContentMatchExps: []ContentMatchExp{
+		{ID: "xorp", Exp: `xorp-\w+`, RuleMetadata: meta},
+		{ID: "plain", Exp: `plain`},
+		{ID: "empty", Exp: `empty`, RuleMetadata: RuleMetadata{Tags: []string{}, References: []string{}}},
+	}})
+	if len(errs) > 0 {
+		t.Fatalf("NewScanner() errors = %v", errs)
+	}
+	got, err := s.ScanReader(context.TODO(), "a.txt", strings.NewReader("xorp-1 plain empty"))
+	if err != nil {
+		t.Fatalf("Scanner.ScanReader() error = %v", err)
+	}
+	if len(got) != 3 || got[0].Rule == nil || !reflect.DeepEqual(*got[0].Rule, meta) || got[1].Rule != nil || got[2].Rule != nil {
+		t.Errorf("Scanner.ScanReader() = %v, want the metadata on the xorp match only", got)
+	}
+	_, errs = NewScanner(Expressions{
+		FileMatchExps:    []FileMatchExp{{ID: "f", Exp: "f", RuleMetadata: RuleMetadata{Severity: "urgent"}}},
+		ContentMatchExps: []ContentMatchExp{{ID: "c", Exp: "c", RuleMetadata: RuleMetadata{Confidence: "certain"}}},
+	})
+	if len(errs) != 2 {
+		t.Errorf("NewScanner() errors = %v, want 2 errors", errs)
+	}
+}
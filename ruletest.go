@@ -0,0 +1,72 @@
+package mres
+
+import (
+	"fmt"
+)
+
+type (
+	// RuleExamples are the examples a rule is tested with by ValidateExpressions.
+	// They are file paths for file rules and lines for content rules.
+	RuleExamples struct {
+		// Positive examples must match
+		Positive []string `json:"positive,omitempty"`
+		// Negative examples must not match
+		Negative []string `json:"negative,omitempty"`
+	}
+
+	// RuleTestError is reported by ValidateExpressions for an example of a rule with the wrong outcome
+	RuleTestError struct {
+		ExpID   string
+		Example string
+		// Positive is set if the example should have matched
+		Positive bool
+	}
+)
+
+func (e *RuleTestError) Error() string {
+	if e.Positive {
+		return fmt.Sprintf("rule: %s did not match positive example: %q", e.ExpID, e.Example)
+	}
+	return fmt.Sprintf("rule: %s matched negative example: %q", e.ExpID, e.Example)
+}
+
+// ValidateExpressions compiles the expressions and tests every rule with its examples. It returns the compile
+// errors and a *RuleTestError for every failed example. Content examples run through the entropy filter and
+// the validator of the rule, the file filter is not applied.
+func ValidateExpressions(exps Expressions) []error {
+	fileMatchers, errs := buildFileMatchers(exps.FileMatchExps)
+	filters, filterErrs := buildFileFilters(exps.FileFilters)
+	errs = append(errs, filterErrs...)
+	contentMatchers, contentErrs := buildContentMatchers(exps.ContentMatchExps, filters)
+	errs = append(errs, contentErrs...)
+	if len(errs) > 0 {
+		return errs
+	}
+	for i, e := range exps.FileMatchExps {
+		m := fileMatchers[i]
+		for _, example := range e.Examples.Positive {
+			if !m.match([]byte(example)) {
+				errs = append(errs, &RuleTestError{ExpID: e.ID, Example: example, Positive: true})
+			}
+		}
+		for _, example := range e.Examples.Negative {
+			if m.match([]byte(example)) {
+				errs = append(errs, &RuleTestError{ExpID: e.ID, Example: example})
+			}
+		}
+	}
+	for i, e := range exps.ContentMatchExps {
+		m := contentMatchers[i : i+1]
+		for _, example := range e.Examples.Positive {
+			if len(m.matchLine("", 1, []byte(example))) == 0 {
+				errs = append(errs, &RuleTestError{ExpID: e.ID, Example: example, Positive: true})
+			}
+		}
+		for _, example := range e.Examples.Negative {
+			if len(m.matchLine("", 1, []byte(example))) > 0 {
+				errs = append(errs, &RuleTestError{ExpID: e.ID, Example: example})
+			}
+		}
+	}
+	return errs
+}
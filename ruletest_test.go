@@ -0,0 +1,76 @@
+package mres
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestValidateExpressions(t *testing.T) {
+	tests := []struct {
+		name        string
+		exps        Expressions
+		want        []RuleTestError
+		wantCompile int
+	}{
+		{
+			name: "all examples pass",
+			exps: Expressions{
+				FileMatchExps: []FileMatchExp{
+					{ID: "pem", Exp: `\.pem$`, Examples: RuleExamples{Positive: []string{"certs/key.pem"}, Negative: []string{"pem.go"}}},
+				},
+				ContentMatchExps: []ContentMatchExp{
+					{ID: "xorp", Exp: `xorp-\d+`, Examples: RuleExamples{Positive: []string{"token: xorp-123"}, Negative: []string{"xorp-abc"}}},
+					{ID: "card", Exp: `\d{16}`, Validator: "luhn", Examples: RuleExamples{Positive: []string{"4111111111111111"}, Negative: []string{"4111111111111112"}}},
+				},
+			},
+			want: []RuleTestError{},
+		},
+		{
+			name: "failing examples",
+			exps: Expressions{
+				FileMatchExps: []FileMatchExp{
+					{ID: "pem", Exp: `.pem`, Examples: RuleExamples{Positive: []string{"key.crt"}, Negative: []string{"xpem.go"}}},
+				},
+				ContentMatchExps: []ContentMatchExp{
+					{ID: "xorp", Exp: `xorp-\w+`, Examples: RuleExamples{Positive: []string{"xorp-123", "XORP-123"}, Negative: []string{"xorp-abc"}}},
+				},
+			},
+			want: []RuleTestError{
+				{ExpID: "pem", Example: "key.crt", Positive: true},
+				{ExpID: "pem", Example: "xpem.go"},
+				{ExpID: "xorp", Example: "XORP-123", Positive: true},
+				{ExpID: "xorp", Example: "xorp-abc"},
+			},
+		},
+		{
+			name: "compile errors",
+			exps: Expressions{
+				FileMatchExps:    []FileMatchExp{{ID: "bad", Exp: `(`}},
+				ContentMatchExps: []ContentMatchExp{{ID: "bad", Exp: `[`}},
+			},
+			wantCompile: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := ValidateExpressions(tt.exps)
+			got := make([]RuleTestError, 0)
+			compile := 0
+			for _, err := range errs {
+				var testErr *RuleTestError
+				if errors.As(err, &testErr) {
+					got = append(got, *testErr)
+					continue
+				}
+				compile++
+			}
+			if compile != tt.wantCompile {
+				t.Errorf("ValidateExpressions() compile errors = %v, want %v", errs, tt.wantCompile)
+			}
+			if tt.want != nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ValidateExpressions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package mres
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// fileOpener opens the files of the jobs produced by the walker
+type fileOpener func(job scanJob) (fs.File, error)
+
+func openOSFile(job scanJob) (fs.File, error) {
+	return os.Open(job.path)
+}
+
+// ScanFSWithCallback is like ScanWithCallback but scans the paths in fsys instead of the OS filesystem,
+// e.g. an embed.FS, a zip.Reader or a fstest.MapFS. Use "." to scan the whole fsys.
+// The paths in the results are fsys paths. WalkOptions.FollowSymlinks and WalkOptions.OneFileSystem
+// do not apply, the directories are walked by a single goroutine.
+func (s *Scanner) ScanFSWithCallback(
+	ctx context.Context, fsys fs.FS, pathsToScan []string, workerCount int,
+	onFileMatchResult func(r FileMatchResult),
+	onContentMatchResult func(r ContentMatchResult),
+	onError func(e error)) {
+	if fsys == nil || len(pathsToScan) == 0 {
+		onError(ErrInvalidArgument)
+		return
+	}
+	walk := func(workerCount int, jobC chan<- scanJob, errorC chan<- error) {
+		s.walkFS(ctx, fsys, pathsToScan, jobC, errorC)
+	}
+	open := func(job scanJob) (fs.File, error) {
+		return fsys.Open(job.path)
+	}
+	s.scanWithCallback(ctx, workerCount, walk, open, onFileMatchResult, onContentMatchResult, onError)
+}
+
+// ScanFS is like Scan but scans the paths in fsys instead of the OS filesystem.
+// For continuous callback, check ScanFSWithCallback method.
+func (s *Scanner) ScanFS(ctx context.Context, fsys fs.FS, pathsToScan []string, workerCount int) (MatchResult, []error) {
+	return collectResults(func(
+		onFileMatchResult func(r FileMatchResult),
+		onContentMatchResult func(r ContentMatchResult),
+		onError func(e error)) {
+		s.ScanFSWithCallback(ctx, fsys, pathsToScan, workerCount, onFileMatchResult, onContentMatchResult, onError)
+	})
+}
+
+// walkFS walks the paths in fsys and produces jobs for the workers
+func (s *Scanner) walkFS(ctx context.Context, fsys fs.FS, pathsToScan []string, jobsC chan<- scanJob, errorsC chan<- error) {
+	defer func() {
+		s.logger.Debug("Closing jobs channel")
+		close(jobsC)
+	}()
+	opts := s.walkOptions
+	for _, root := range pathsToScan {
+		s.logger.Debug(fmt.Sprintf("Walking path: %s", root))
+		rootDepth := pathDepth(root)
+		err := fs.WalkDir(fsys, root, func(path string, d fs.DirEntry, err error) error {
+			if ctx.Err() != nil {
+				return errReceivedCancellation
+			}
+			if err != nil {
+				errorsC <- err // normal errors send it to error channel
+				return nil
+			}
+			if path != root && opts.SkipHidden && isHidden(d.Name()) {
+				if d.IsDir() {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			if d.IsDir() {
+				if path != root && opts.MaxDepth > 0 && pathDepth(path)-rootDepth >= opts.MaxDepth {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			if d.Type()&fs.ModeSymlink == fs.ModeSymlink || (opts.SkipSpecialFiles && isSpecialMode(d.Type())) {
+				return nil
+			}
+			select {
+			case <-ctx.Done():
+				return errReceivedCancellation
+			case jobsC <- scanJob{path: path}:
+			}
+			return nil
+		})
+		if err == errReceivedCancellation {
+			s.logger.Debug("Received cancellation. Not walking the paths further")
+			return
+		}
+		if err != nil {
+			errorsC <- err
+		}
+	}
+}
+
+// pathDepth is the number of elements of a slash separated fs path, "." has none
+func pathDepth(path string) int {
+	if path == "." {
+		return 0
+	}
+	return strings.Count(path, "/") + 1
+}
@@ -0,0 +1,79 @@
+package mres
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+	"testing/fstest"
+)
+
+func TestScanner_ScanFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"config.json":           {Data: []byte("{\n    \"token\": \"xorp-9836465734687356\"\n}")},
+		"src/main.go":           {Data: []byte("package main\n// token: xorp-1234\n")},
+		"src/.env":              {Data: []byte("TOKEN=xorp-5678\n")},
+		"src/vendor/lib/lib.go": {Data: []byte("// xorp-0000\n")},
+		"release.zip":           {Data: newTestZip(t, map[string][]byte{"app.yaml": []byte("token: xorp-zip")})},
+	}
+	exps := Expressions{ContentMatchExps: []ContentMatchExp{{ID: "xorp", Exp: `xorp-\w+`}}}
+	tests := []struct {
+		name     string
+		paths    []string
+		walkOpts WalkOptions
+		archives bool
+		want     []string
+		wantErrs int
+	}{
+		{
+			name:     "whole fs",
+			paths:    []string{"."},
+			archives: true,
+			want: []string{
+				"config.json:2:xorp-9836465734687356",
+				"release.zip!/app.yaml:1:xorp-zip",
+				"src/.env:1:xorp-5678",
+				"src/main.go:2:xorp-1234",
+				"src/vendor/lib/lib.go:1:xorp-0000",
+			},
+		},
+		{
+			name:     "sub folder with walk options",
+			paths:    []string{"src"},
+			walkOpts: WalkOptions{SkipHidden: true, MaxDepth: 2},
+			want:     []string{"src/main.go:2:xorp-1234"},
+		},
+		{
+			name:     "missing path",
+			paths:    []string{"missing"},
+			want:     []string{},
+			wantErrs: 1,
+		},
+		{
+			name:     "no paths",
+			paths:    []string{},
+			want:     []string{},
+			wantErrs: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := NewScanner(exps)
+			s.SetWalkOptions(tt.walkOpts)
+			s.SetArchiveOptions(ArchiveOptions{Enabled: tt.archives})
+			got, errs := s.ScanFS(context.TODO(), fsys, tt.paths, 2)
+			matches := make([]string, 0)
+			for _, r := range got.ContentMatches {
+				matches = append(matches, fmt.Sprintf("%s:%d:%s", r.FilePath, r.LineNumber, r.MatchString))
+			}
+			sort.Strings(matches)
+			if !reflect.DeepEqual(matches, tt.want) {
+				t.Errorf("Scanner.ScanFS() content matches = %v, want %v", matches, tt.want)
+			}
+			if len(errs) != tt.wantErrs {
+				t.Errorf("Scanner.ScanFS() errors = %v, want %v", errs, tt.wantErrs)
+			}
+		})
+	}
+}
@@ -3,8 +3,6 @@ package mres
 import (
 	"context"
 	"fmt"
-	"os"
-	"path/filepath"
 	"sync"
 )
 
@@ -12,6 +10,8 @@ type (
 	Expressions struct {
 		FileMatchExps    []FileMatchExp    `json:"file_match_exps,omitempty"`
 		ContentMatchExps []ContentMatchExp `json:"content_match_exps,omitempty"`
+		// FileFilters are named file filters, content rules refer to them by id with ContentMatchExp.FileFilter
+		FileFilters []FileMatchExp `json:"file_filters,omitempty"`
 	}
 
 	MatchResult struct {
@@ -27,9 +27,19 @@ type (
 
 	//Scanner use mres.NewScanner for creating new scanner
 	Scanner struct {
-		fileMatchers    fileMatchers
-		contentMatchers contentMatchers
-		logger          ILogger
+		fileMatchers      fileMatchers
+		contentMatchers   contentMatchers
+		logger            ILogger
+		walkOptions       WalkOptions
+		archiveOptions    ArchiveOptions
+		decompressOptions DecompressOptions
+		cache             *ScanCache
+		matchLimits       MatchLimits
+		fileLimits        FileLimits
+		encodingFilters   []encodingFilter
+		// expressionsHash identifies the expressions of the scanner in the cache
+		expressionsHash string
+		lintWarnings    []*LintIssue
 	}
 )
 
@@ -53,10 +63,37 @@ func (s *Scanner) ScanWithCallback(
 		onError(ErrInvalidArgument)
 		return
 	}
+	s.scanPathsWithCallback(ctx, pathsToScan, nil, workerCount, onFileMatchResult, onContentMatchResult, onError)
+}
+
+// scanPathsWithCallback scans the paths like ScanWithCallback, depths are the depths of the paths below
+// the paths WalkOptions.MaxDepth is counted from, nil if they are those paths
+func (s *Scanner) scanPathsWithCallback(
+	ctx context.Context, pathsToScan []string, depths []int, workerCount int,
+	onFileMatchResult func(r FileMatchResult),
+	onContentMatchResult func(r ContentMatchResult),
+	onError func(e error)) {
+	if s.cache != nil {
+		s.cache.useRules(s.cacheRulesHash())
+	}
+	walk := func(workerCount int, jobC chan<- scanJob, errorC chan<- error) {
+		s.walkPaths(ctx, pathsToScan, depths, workerCount, jobC, errorC)
+	}
+	s.scanWithCallback(ctx, workerCount, walk, openOSFile, onFileMatchResult, onContentMatchResult, onError)
+}
+
+// scanWithCallback runs the workers on the jobs produced by walk, the files are opened with open
+func (s *Scanner) scanWithCallback(
+	ctx context.Context, workerCount int,
+	walk func(workerCount int, jobC chan<- scanJob, errorC chan<- error), open fileOpener,
+	onFileMatchResult func(r FileMatchResult),
+	onContentMatchResult func(r ContentMatchResult),
+	onError func(e error)) {
 	if workerCount < 1 {
 		workerCount = 1
 	}
-	jobC := make(chan string, workerCount*10)
+	onContentMatchResult = s.limitRuleMatches(onContentMatchResult)
+	jobC := make(chan scanJob, workerCount*10)
 	fmResultC := make(chan FileMatchResult, workerCount)
 	cmResultC := make(chan ContentMatchResult, workerCount)
 	errorC := make(chan error, workerCount)
@@ -64,11 +101,18 @@ func (s *Scanner) ScanWithCallback(
 	wg := new(sync.WaitGroup)
 	for w := 1; w <= workerCount; w++ {
 		wg.Add(1)
-		go s.scanWorker(ctx, w, wg, jobC, fmResultC, cmResultC, errorC)
+		go s.scanWorker(ctx, w, wg, open, jobC, fmResultC, cmResultC, errorC)
 	}
-	go s.walkPaths(ctx, pathsToScan, jobC, errorC)
+	walkerWg := new(sync.WaitGroup)
+	walkerWg.Add(1)
+	go func() {
+		defer walkerWg.Done()
+		walk(workerCount, jobC, errorC)
+	}()
 	go func() {
 		wg.Wait()
+		// the walker can still report errors when the workers are stopped by cancellation
+		walkerWg.Wait()
 		s.logger.Debug("Closing result and error channels")
 		close(fmResultC)
 		close(cmResultC)
@@ -114,6 +158,19 @@ func (s *Scanner) ScanWithCallback(
 // If you want to stop the scan, call cancel on the context passed.
 // For continuous callback, check ScanWithCallback method.
 func (s *Scanner) Scan(ctx context.Context, foldersToScan []string, workerCount int) (MatchResult, []error) {
+	return collectResults(func(
+		onFileMatchResult func(r FileMatchResult),
+		onContentMatchResult func(r ContentMatchResult),
+		onError func(e error)) {
+		s.ScanWithCallback(ctx, foldersToScan, workerCount, onFileMatchResult, onContentMatchResult, onError)
+	})
+}
+
+// collectResults runs scan and collects all its results and errors
+func collectResults(scan func(
+	onFileMatchResult func(r FileMatchResult),
+	onContentMatchResult func(r ContentMatchResult),
+	onError func(e error))) (MatchResult, []error) {
 	fmResults := make([]FileMatchResult, 0)
 	cmResults := make([]ContentMatchResult, 0)
 	errors := make([]error, 0)
@@ -126,7 +183,7 @@ func (s *Scanner) Scan(ctx context.Context, foldersToScan []string, workerCount
 	onError := func(e error) {
 		errors = append(errors, e)
 	}
-	s.ScanWithCallback(ctx, foldersToScan, workerCount, onFileMatchResult, onContentMatchResult, onError)
+	scan(onFileMatchResult, onContentMatchResult, onError)
 	result := MatchResult{
 		FileMatches:    fmResults,
 		ContentMatches: cmResults,
@@ -134,42 +191,9 @@ func (s *Scanner) Scan(ctx context.Context, foldersToScan []string, workerCount
 	return result, errors
 }
 
-//walkPaths walks the folders and produces jobs for the workers
-func (s *Scanner) walkPaths(ctx context.Context, pathsToScan []string, jobsC chan<- string, errorsC chan<- error) {
-	processPath := func(path string, f os.FileInfo, err error) error {
-		select {
-		case <-ctx.Done():
-			return errReceivedCancellation
-		default:
-			if err != nil {
-				errorsC <- err // normal errors send it to error channel
-				return nil
-			}
-			if !f.IsDir() && (f.Mode()&os.ModeSymlink) != os.ModeSymlink { // skipping directory & symlink
-				jobsC <- path
-			}
-			return nil
-		}
-	}
-	for _, f := range pathsToScan {
-		s.logger.Debug(fmt.Sprintf("Walking path: %s", f))
-		err := filepath.Walk(f, processPath)
-		if err != nil {
-			if err == errReceivedCancellation {
-				s.logger.Debug("Received cancellation. Not walking the paths further")
-				break
-			} else {
-				errorsC <- err
-			}
-		}
-	}
-	s.logger.Debug("Closing jobs channel")
-	close(jobsC)
-}
-
 func (s *Scanner) scanWorker(
-	ctx context.Context, workerID int, wg *sync.WaitGroup,
-	jobC <-chan string, fmResultC chan<- FileMatchResult, cmResultC chan<- ContentMatchResult, errorC chan<- error) {
+	ctx context.Context, workerID int, wg *sync.WaitGroup, open fileOpener,
+	jobC <-chan scanJob, fmResultC chan<- FileMatchResult, cmResultC chan<- ContentMatchResult, errorC chan<- error) {
 	defer func() {
 		wg.Done()
 		s.logger.Debug(fmt.Sprintf("Stopped worker: %d", workerID))
@@ -181,21 +205,37 @@ func (s *Scanner) scanWorker(
 		case <-ctx.Done():
 			s.logger.Debug(fmt.Sprintf("Force stopping worker: %d", workerID))
 			return
-		case filePath, ok := <-jobC:
+		case job, ok := <-jobC:
 			if !ok {
 				return
 			}
-			for _, r := range s.fileMatchers.matchAll(filePath) {
-				fmResultC <- r
+			for _, r := range s.fileMatchers.matchAll(job.path) {
+				fmResultC <- job.fileResult(r)
 			}
-			contentResults, err := s.contentMatchers.matchAll(filePath, bufPool)
-			if err != nil {
-				errorC <- err
-				continue
+			jobCtx, cancel := s.jobContext(ctx)
+			jobOpen := s.limitedOpener(jobCtx, open)
+			if s.archiveOptions.Enabled {
+				if format := getArchiveFormat(job.path); format != formatNone {
+					s.scanArchiveFile(jobCtx, job, format, jobOpen, bufPool, fmResultC, cmResultC, errorC)
+					if err := s.timeoutError(ctx, jobCtx, job, nil); err != nil {
+						errorC <- err
+					}
+					cancel()
+					continue
+				}
+			}
+			matchContent := s.matchFileContent
+			if s.cache != nil && job.cacheable {
+				matchContent = s.matchFileContentCached
 			}
+			contentResults, err := matchContent(jobOpen, job, bufPool)
 			for _, r := range contentResults {
-				cmResultC <- r
+				cmResultC <- job.contentResult(r)
 			}
+			if err != nil {
+				errorC <- s.timeoutError(ctx, jobCtx, job, err)
+			}
+			cancel()
 		}
 	}
 }
@@ -203,10 +243,20 @@ func (s *Scanner) scanWorker(
 //NewScanner creates a new scanner
 func NewScanner(exps Expressions) (*Scanner, []error) {
 	fileMatchers, errs1 := buildFileMatchers(exps.FileMatchExps)
-	contentMatchers, errs2 := buildContentMatchers(exps.ContentMatchExps)
-	errs := make([]error, 0, len(errs1)+len(errs2))
+	filters, errs2 := buildFileFilters(exps.FileFilters)
+	contentMatchers, errs3 := buildContentMatchers(exps.ContentMatchExps, filters)
+	errs := make([]error, 0, len(errs1)+len(errs2)+len(errs3))
 	errs = append(errs, errs1...)
 	errs = append(errs, errs2...)
+	errs = append(errs, errs3...)
+	lintWarnings := make([]*LintIssue, 0)
+	for _, issue := range LintExpressions(exps) {
+		if issue.Level == LintError {
+			errs = append(errs, issue)
+			continue
+		}
+		lintWarnings = append(lintWarnings, issue)
+	}
 	if len(errs) > 0 {
 		return nil, errs
 	}
@@ -214,7 +264,12 @@ func NewScanner(exps Expressions) (*Scanner, []error) {
 		fileMatchers:    fileMatchers,
 		contentMatchers: contentMatchers,
 		logger:          &noopLogger{},
+		encodingFilters: buildEncodingFilters(exps.FileFilters, filters),
+		expressionsHash: hashExpressions(exps),
+		lintWarnings:    lintWarnings,
 	}
+	scanner.SetArchiveOptions(ArchiveOptions{})
+	scanner.SetDecompressOptions(DecompressOptions{})
 	return scanner, nil
 }
 
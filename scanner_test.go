@@ -26,35 +26,50 @@ func TestScanner_Scan(t *testing.T) {
 		ctx     context.Context
 		folders []string
 	}
+	emptyResult := MatchResult{FileMatches: []FileMatchResult{}, ContentMatches: []ContentMatchResult{}}
 	tests := []struct {
 		name  string
 		args  args
-		want  []MatchResult
+		want  MatchResult
 		want1 []error
 	}{
 		{
 			name:  "just try",
 			args:  args{ctx: context.TODO(), folders: []string{"./testdatazz"}},
-			want:  []MatchResult{},
-			want1: []error{errors.New("lstat ./testdatazz: no such file or directory")}, // TODO: better way to assert
+			want:  emptyResult,
+			want1: []error{errors.New("lstat ./testdatazz: no such file or directory")},
 		},
 		{
 			name:  "just try 2",
 			args:  args{ctx: context.TODO(), folders: []string{"./testdata"}},
-			want:  []MatchResult{},
+			want:  emptyResult,
 			want1: []error{},
 		},
+		{
+			name:  "no folders",
+			args:  args{ctx: context.TODO(), folders: []string{}},
+			want:  emptyResult,
+			want1: []error{ErrInvalidArgument},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			s := getTestScanner(t, tt.args.folders)
-			got, got1 := s.Scan(tt.args.ctx, []string{}, 1)
-			if !reflect.DeepEqual(got, tt.want) { // TODO: better way to assert
+			got, got1 := s.Scan(tt.args.ctx, tt.args.folders, 1)
+			if !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("Scanner.Scan() got = %v, want %v", got, tt.want)
 			}
-			if !reflect.DeepEqual(got1, tt.want1) {
+			if errorStrings(got1) != errorStrings(tt.want1) {
 				t.Errorf("Scanner.Scan() got1 = %v, want %v", got1, tt.want1)
 			}
 		})
 	}
 }
+
+func errorStrings(errs []error) string {
+	s := ""
+	for _, e := range errs {
+		s += e.Error() + "\n"
+	}
+	return s
+}
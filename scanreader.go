@@ -0,0 +1,48 @@
+package mres
+
+import (
+	"context"
+	"io"
+)
+
+// contextReader stops reading once the context is cancelled
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+// ScanReader runs the content expressions over the content read from r, e.g. os.Stdin.
+// name is reported as the file path of the results and the file filters of the expressions are matched against it.
+// If you want to stop the scan, call cancel on the context passed.
+func (s *Scanner) ScanReader(ctx context.Context, name string, r io.Reader) ([]ContentMatchResult, error) {
+	if r == nil {
+		return make([]ContentMatchResult, 0), ErrInvalidArgument
+	}
+	applicableMatchers := s.contentMatchers.filterApplicable(name)
+	if len(applicableMatchers) == 0 {
+		return make([]ContentMatchResult, 0), nil
+	}
+	bufPool := make([]byte, 0, 10*1024*1024)
+	var cr io.Reader = &contextReader{ctx: ctx, r: r}
+	if s.decompressOptions.Enabled {
+		dr, closeFn := s.decompressReader(name, cr)
+		defer closeFn()
+		cr = dr
+	}
+	results, err := s.matchDecoded(applicableMatchers, name, cr, bufPool)
+	limited := make([]ContentMatchResult, 0, len(results))
+	keep := s.limitRuleMatches(func(r ContentMatchResult) {
+		limited = append(limited, r)
+	})
+	for _, r := range results {
+		keep(r)
+	}
+	return limited, err
+}
+
+func (c *contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
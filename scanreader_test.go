@@ -0,0 +1,47 @@
+package mres
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestScanner_ScanReader(t *testing.T) {
+	exps := Expressions{ContentMatchExps: []ContentMatchExp{
+		{ID: "xorp", Exp: `xorp-\w+`},
+		{ID: "sql-password", Exp: `password='\w+'`, FileFilterEnabled: true, FileMatchExp: FileMatchExp{Exp: `\.sql$`}},
+	}}
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		fileName  string
+		content   string
+		wantCount int
+		wantErr   error
+	}{
+		{name: "stream", ctx: context.TODO(), fileName: "-", content: "a\nxorp-1\nb xorp-2", wantCount: 2},
+		{name: "file filter applied on name", ctx: context.TODO(), fileName: "dump.sql", content: "xorp-1 password='x'", wantCount: 2},
+		{name: "file filter not matching name", ctx: context.TODO(), fileName: "-", content: "xorp-1 password='x'", wantCount: 1},
+		{name: "cancelled", ctx: cancelled, fileName: "-", content: "xorp-1", wantCount: 0, wantErr: context.Canceled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := NewScanner(exps)
+			got, err := s.ScanReader(tt.ctx, tt.fileName, strings.NewReader(tt.content))
+			if len(got) != tt.wantCount {
+				t.Errorf("Scanner.ScanReader() = %v, want %v", got, tt.wantCount)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Scanner.ScanReader() err = %v, want %v", err, tt.wantErr)
+			}
+			for _, r := range got {
+				if r.FilePath != tt.fileName {
+					t.Errorf("Scanner.ScanReader() file path = %v, want %v", r.FilePath, tt.fileName)
+				}
+			}
+		})
+	}
+}
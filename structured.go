@@ -0,0 +1,403 @@
+package mres
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	formatUnstructured structuredFormat = ""
+	formatJSON         structuredFormat = "json"
+	formatYAML         structuredFormat = "yaml"
+	formatTOML         structuredFormat = "toml"
+	formatINI          structuredFormat = "ini"
+	formatEnv          structuredFormat = "env"
+)
+
+// identifierKey finds the keys written as .key in key paths, the others are written as ["key"]
+var identifierKey = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+type (
+	// structuredFormat is the format of the files structured rules apply to, detected by the file name
+	structuredFormat string
+
+	// structuredValue is a scalar value of a structured file with its key path, e.g. $.db.password
+	structuredValue struct {
+		keyPath string
+		value   string
+		line    int
+	}
+
+	// jsonFrame is an object or an array being parsed
+	jsonFrame struct {
+		keyPath string
+		object  bool
+		wantKey bool
+		key     string
+		index   int
+	}
+)
+
+// getStructuredFormat detects the format of a structured file by its name, formatUnstructured if it is not one
+func getStructuredFormat(name string) structuredFormat {
+	base := strings.ToLower(path.Base(name))
+	switch {
+	case hasAnySuffix(base, ".json"):
+		return formatJSON
+	case hasAnySuffix(base, ".yaml", ".yml"):
+		return formatYAML
+	case hasAnySuffix(base, ".toml"):
+		return formatTOML
+	case hasAnySuffix(base, ".ini"):
+		return formatINI
+	case base == ".env" || strings.HasPrefix(base, ".env.") || hasAnySuffix(base, ".env"):
+		return formatEnv
+	}
+	return formatUnstructured
+}
+
+// splitStructured returns the matchers of the lines and the structured ones
+func (matchers contentMatchers) splitStructured() (contentMatchers, contentMatchers) {
+	lineMatchers := make(contentMatchers, 0, len(matchers))
+	var structuredMatchers contentMatchers
+	for _, m := range matchers {
+		if m.keyExp != nil {
+			structuredMatchers = append(structuredMatchers, m)
+			continue
+		}
+		lineMatchers = append(lineMatchers, m)
+	}
+	return lineMatchers, structuredMatchers
+}
+
+// matchStructured parses the content read from r and runs the structured matchers over its values.
+// It returns a reader of the whole content for the line matchers. The content larger than the buffer
+// is not parsed.
+func (matchers contentMatchers) matchStructured(name string, r io.Reader, bufPool []byte, limiter *fileMatchLimiter) ([]ContentMatchResult, io.Reader, error) {
+	results := make([]ContentMatchResult, 0)
+	content, err := io.ReadAll(io.LimitReader(r, int64(cap(bufPool))+1))
+	rest := io.MultiReader(bytes.NewReader(content), r)
+	if err != nil {
+		return results, rest, fmt.Errorf("error: %w while reading: %s", err, name)
+	}
+	if len(content) > cap(bufPool) {
+		return results, rest, fmt.Errorf("%w: file: %s is larger than %d bytes, its structured content is not scanned", ErrStructuredTooLarge, name, cap(bufPool))
+	}
+	format := getStructuredFormat(name)
+	values, err := parseStructured(format, content)
+	if err != nil {
+		return results, rest, fmt.Errorf("error: %w while parsing: %s as %s", err, name, format)
+	}
+	for _, v := range values {
+		for _, m := range matchers {
+			if !m.keyExp.MatchString(v.keyPath) {
+				continue
+			}
+			valueResults := m.match(name, v.line, []byte(v.value))
+			for i := range valueResults {
+				valueResults[i].KeyPath = v.keyPath
+			}
+			if limiter == nil {
+				results = append(results, valueResults...)
+				continue
+			}
+			// the values are few, the matches of the rules done with the file are dropped by the limiter
+			kept, fileDone, _ := limiter.add(valueResults)
+			results = append(results, kept...)
+			if fileDone {
+				return results, rest, nil
+			}
+		}
+	}
+	return results, rest, nil
+}
+
+// parseStructured returns the scalar values of the content in their order in the file
+func parseStructured(format structuredFormat, content []byte) ([]structuredValue, error) {
+	switch format {
+	case formatJSON:
+		return parseJSON(content)
+	case formatYAML:
+		return parseYAML(content)
+	case formatTOML, formatINI, formatEnv:
+		return parseKeyValueLines(format, content), nil
+	}
+	return nil, fmt.Errorf("%w: unknown structured format: %s", ErrInvalidArgument, format)
+}
+
+// keyPathOf appends a key to a key path
+func keyPathOf(parent string, key string) string {
+	if identifierKey.MatchString(key) {
+		return parent + "." + key
+	}
+	return parent + "[" + strconv.Quote(key) + "]"
+}
+
+// indexPathOf appends an array index to a key path
+func indexPathOf(parent string, index int) string {
+	return fmt.Sprintf("%s[%d]", parent, index)
+}
+
+// parseJSON reads the tokens of the JSON values of the content, the line of a value is the one it ends on
+func parseJSON(content []byte) ([]structuredValue, error) {
+	values := make([]structuredValue, 0)
+	lineStarts := lineOffsets(content)
+	dec := json.NewDecoder(bytes.NewReader(content))
+	dec.UseNumber()
+	var stack []*jsonFrame
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return values, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		var top *jsonFrame
+		keyPath := "$"
+		if len(stack) > 0 {
+			top = stack[len(stack)-1]
+			if top.object && top.wantKey {
+				// the tokenizer only returns strings as keys
+				if key, ok := tok.(string); ok {
+					top.key = key
+					top.wantKey = false
+					continue
+				}
+			}
+			if top.object {
+				keyPath = keyPathOf(top.keyPath, top.key)
+			} else {
+				keyPath = indexPathOf(top.keyPath, top.index)
+			}
+		}
+		switch t := tok.(type) {
+		case json.Delim:
+			if t == '{' || t == '[' {
+				stack = append(stack, &jsonFrame{keyPath: keyPath, object: t == '{', wantKey: t == '{'})
+				continue
+			}
+			stack = stack[:len(stack)-1]
+			if len(stack) > 0 {
+				stack[len(stack)-1].next()
+			}
+			continue
+		case string:
+			values = append(values, structuredValue{keyPath: keyPath, value: t, line: lineOf(lineStarts, int(dec.InputOffset()))})
+		case json.Number:
+			values = append(values, structuredValue{keyPath: keyPath, value: t.String(), line: lineOf(lineStarts, int(dec.InputOffset()))})
+		case bool:
+			values = append(values, structuredValue{keyPath: keyPath, value: strconv.FormatBool(t), line: lineOf(lineStarts, int(dec.InputOffset()))})
+		}
+		if top != nil {
+			top.next()
+		}
+	}
+}
+
+// next moves the frame to its next member once a value is read
+func (f *jsonFrame) next() {
+	if f.object {
+		f.wantKey = true
+		return
+	}
+	f.index++
+}
+
+// lineOffsets returns the offsets where the lines of the content start
+func lineOffsets(content []byte) []int {
+	starts := []int{0}
+	for i, b := range content {
+		if b == '\n' {
+			starts = append(starts, i+1)
+		}
+	}
+	return starts
+}
+
+// lineOf returns the line, from 1, of the byte before offset
+func lineOf(lineStarts []int, offset int) int {
+	return sort.SearchInts(lineStarts, offset)
+}
+
+// parseYAML walks the nodes of the YAML documents of the content. Aliases are not followed.
+func parseYAML(content []byte) ([]structuredValue, error) {
+	values := make([]structuredValue, 0)
+	dec := yaml.NewDecoder(bytes.NewReader(content))
+	for {
+		var doc yaml.Node
+		err := dec.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			return values, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		values = walkYAML(values, "$", &doc)
+	}
+}
+
+func walkYAML(values []structuredValue, keyPath string, n *yaml.Node) []structuredValue {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		for _, c := range n.Content {
+			values = walkYAML(values, keyPath, c)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			values = walkYAML(values, keyPathOf(keyPath, n.Content[i].Value), n.Content[i+1])
+		}
+	case yaml.SequenceNode:
+		for i, c := range n.Content {
+			values = walkYAML(values, indexPathOf(keyPath, i), c)
+		}
+	case yaml.ScalarNode:
+		if n.Tag != "!!null" {
+			values = append(values, structuredValue{keyPath: keyPath, value: n.Value, line: n.Line})
+		}
+	}
+	return values
+}
+
+// parseKeyValueLines reads the key = value lines of TOML, INI and .env files and their [sections].
+// The comments after the values are dropped, # ones and ; ones in INI files.
+// TOML arrays and inline tables are values as they are written, multi-line values are not supported.
+func parseKeyValueLines(format structuredFormat, content []byte) []structuredValue {
+	values := make([]structuredValue, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(content)+1)
+	scanner.Split(scanAnyLines)
+	section := "$"
+	arrayTables := make(map[string]int)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || (format == formatINI && line[0] == ';') {
+			continue
+		}
+		if format != formatEnv && line[0] == '[' {
+			section = sectionPath(format, line, arrayTables)
+			continue
+		}
+		sep := strings.IndexByte(line, '=')
+		if format == formatINI {
+			if colon := strings.IndexByte(line, ':'); colon >= 0 && (sep < 0 || colon < sep) {
+				sep = colon
+			}
+		}
+		if sep <= 0 {
+			continue
+		}
+		key := strings.TrimSpace(line[:sep])
+		value := strings.TrimSpace(line[sep+1:])
+		keyPath := section
+		switch format {
+		case formatTOML:
+			for _, k := range splitTOMLKey(key) {
+				keyPath = keyPathOf(keyPath, k)
+			}
+			value = unquoteValue(stripComment(value, "#"))
+		case formatEnv:
+			keyPath = keyPathOf(keyPath, strings.TrimSpace(strings.TrimPrefix(key, "export ")))
+			value = unquoteValue(stripComment(value, "#"))
+		default:
+			keyPath = keyPathOf(keyPath, key)
+			value = unquoteValue(stripComment(value, ";#"))
+		}
+		values = append(values, structuredValue{keyPath: keyPath, value: value, line: lineNo})
+	}
+	return values
+}
+
+// sectionPath returns the key path of a [section] line. TOML [[tables]] get the index of their occurrence.
+func sectionPath(format structuredFormat, line string, arrayTables map[string]int) string {
+	if format == formatINI {
+		return keyPathOf("$", strings.TrimSpace(strings.Trim(stripComment(line, ";#"), "[]")))
+	}
+	array := strings.HasPrefix(line, "[[")
+	name := strings.TrimSpace(strings.Trim(stripComment(line, "#"), "[]"))
+	keyPath := "$"
+	for _, k := range splitTOMLKey(name) {
+		keyPath = keyPathOf(keyPath, k)
+	}
+	if array {
+		index := arrayTables[keyPath]
+		arrayTables[keyPath]++
+		keyPath = indexPathOf(keyPath, index)
+	}
+	return keyPath
+}
+
+// splitTOMLKey splits a dotted TOML key, the dots of quoted parts are kept
+func splitTOMLKey(key string) []string {
+	parts := make([]string, 0, 1)
+	var part strings.Builder
+	var quote byte
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		switch {
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+			part.WriteByte(c)
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '.':
+			parts = append(parts, strings.TrimSpace(part.String()))
+			part.Reset()
+		default:
+			part.WriteByte(c)
+		}
+	}
+	return append(parts, strings.TrimSpace(part.String()))
+}
+
+// stripComment drops a comment starting with one of the markers after a value, outside of quotes.
+// The marker starts a comment at the start of the value or after a space, e.g. the # of a URL fragment does not.
+func stripComment(value string, markers string) string {
+	var quote byte
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case quote != 0 && c == '\\' && quote == '"':
+			i++
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+		case c == '"' || c == '\'':
+			quote = c
+		case strings.IndexByte(markers, c) >= 0 && (i == 0 || value[i-1] == ' ' || value[i-1] == '\t'):
+			return strings.TrimSpace(value[:i])
+		}
+	}
+	return value
+}
+
+// unquoteValue removes the quotes of a quoted value, escapes are only interpreted in double quotes
+func unquoteValue(value string) string {
+	if len(value) < 2 || value[0] != value[len(value)-1] {
+		return value
+	}
+	switch value[0] {
+	case '"':
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			return unquoted
+		}
+		return value[1 : len(value)-1]
+	case '\'':
+		return value[1 : len(value)-1]
+	}
+	return value
+}
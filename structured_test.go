@@ -0,0 +1,147 @@
+package mres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_parseStructured(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  structuredFormat
+		content string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:    "json",
+			format:  formatJSON,
+			content: "{\n  \"db\": {\"password\": \"p1\", \"port\": 5432},\n  \"keys\": [\"k0\", {\"a b\": true}],\n  \"none\": null\n}",
+			want:    []string{`2:$.db.password=p1`, `2:$.db.port=5432`, `3:$.keys[0]=k0`, `3:$.keys[1]["a b"]=true`},
+		},
+		{name: "json invalid", format: formatJSON, content: `{"a": }`, wantErr: true},
+		{
+			name:    "yaml",
+			format:  formatYAML,
+			content: "db:\n  password: p1\nkeys:\n  - k0\n  - token: |\n      t1\nnone: ~\n---\nsecond: s\n",
+			want:    []string{`2:$.db.password=p1`, `4:$.keys[0]=k0`, `5:$.keys[1].token=t1` + "\n", `9:$.second=s`},
+		},
+		{name: "yaml invalid", format: formatYAML, content: "a: [", wantErr: true},
+		{
+			name:    "toml",
+			format:  formatTOML,
+			content: "title = \"x\" # comment\n[db.main]\npassword = 'p#1'\nsite.\"a.b\" = \"s\\tt\"\n[[users]]\nname = \"u0\"\n[[users]]\nname = \"u1\"\n",
+			want:    []string{`1:$.title=x`, `3:$.db.main.password=p#1`, `4:$.db.main.site["a.b"]=s` + "\tt", `6:$.users[0].name=u0`, `8:$.users[1].name=u1`},
+		},
+		{
+			name:    "ini",
+			format:  formatINI,
+			content: "; comment\nglobal = g ; comment\r\n[db server] # comment\r\npassword: \"p;1\" # comment\r\nurl = a=b#c\r\n",
+			want:    []string{`2:$.global=g`, `4:$["db server"].password=p;1`, `5:$["db server"].url=a=b#c`},
+		},
+		{
+			name:    "env",
+			format:  formatEnv,
+			content: "# comment\nexport API_TOKEN=\"t1\"\nSECRET=s1 # comment\n[not a section]\nURL=a#b\n",
+			want:    []string{`2:$.API_TOKEN=t1`, `3:$.SECRET=s1`, `5:$.URL=a#b`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := parseStructured(tt.format, []byte(tt.content))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseStructured() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			got := make([]string, 0)
+			for _, v := range values {
+				got = append(got, fmt.Sprintf("%d:%s=%s", v.line, v.keyPath, v.value))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseStructured() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScanner_ScanStructured(t *testing.T) {
+	exps := Expressions{ContentMatchExps: []ContentMatchExp{
+		{ID: "secret-value", KeyExp: `(?i)token|secret`, Exp: `\S+`},
+		{ID: "xorp", Exp: `xorp-\d+`},
+	}}
+	s, errs := NewScanner(exps)
+	if len(errs) > 0 {
+		t.Fatalf("NewScanner() errs = %v", errs)
+	}
+	got, scanErrs := s.Scan(context.TODO(), []string{"./testdata"}, 1)
+	if len(scanErrs) > 0 {
+		t.Fatalf("Scanner.Scan() errs = %v", scanErrs)
+	}
+	want := map[string]ContentMatchResult{
+		"secret-value": {ExpID: "secret-value", LineNumber: 2, MatchString: "xorp-9836465734687356", KeyPath: "$.token"},
+		"xorp":         {ExpID: "xorp", LineNumber: 2, MatchString: "xorp-9836465734687356"},
+	}
+	if len(got.ContentMatches) != len(want) {
+		t.Fatalf("Scanner.Scan() = %v, want %v", got.ContentMatches, want)
+	}
+	for _, r := range got.ContentMatches {
+		if !strings.HasSuffix(r.FilePath, "config.json") {
+			t.Errorf("Scanner.Scan() file path = %v, want testdata/config.json", r.FilePath)
+		}
+		r.FilePath = ""
+		if !reflect.DeepEqual(r, want[r.ExpID]) {
+			t.Errorf("Scanner.Scan() = %+v, want %+v", r, want[r.ExpID])
+		}
+	}
+
+	// structured rules do not apply to other files and the lines are still matched in invalid files
+	tests := []struct {
+		name      string
+		fileName  string
+		content   string
+		wantIDs   []string
+		wantError bool
+	}{
+		{name: "text", fileName: "notes.txt", content: "token: xorp-1\n", wantIDs: []string{"xorp"}},
+		{name: "env", fileName: "prod.env", content: "TOKEN=xorp-1\n", wantIDs: []string{"secret-value", "xorp"}},
+		{name: "invalid json", fileName: "a.json", content: "{\"token\": xorp-1\n", wantIDs: []string{"xorp"}, wantError: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := s.ScanReader(context.TODO(), tt.fileName, strings.NewReader(tt.content))
+			if (err != nil) != tt.wantError {
+				t.Errorf("Scanner.ScanReader() error = %v, wantError %v", err, tt.wantError)
+			}
+			ids := make([]string, 0)
+			for _, r := range results {
+				ids = append(ids, r.ExpID)
+			}
+			if !reflect.DeepEqual(ids, tt.wantIDs) {
+				t.Errorf("Scanner.ScanReader() ids = %v, want %v", ids, tt.wantIDs)
+			}
+		})
+	}
+}
+
+func Test_contentMatchers_matchStructuredTooLarge(t *testing.T) {
+	matchers, errs := buildContentMatchers([]ContentMatchExp{{ID: "secret-value", KeyExp: `token`, Exp: `\S+`}}, nil)
+	if len(errs) > 0 {
+		t.Fatalf("buildContentMatchers() errs = %v", errs)
+	}
+	content := `{"token": "xorp-1", "other": "aaaaaaaaaaaaaaaa"}`
+	results, rest, err := matchers.matchStructured("a.json", strings.NewReader(content), make([]byte, 0, 16), nil)
+	if !errors.Is(err, ErrStructuredTooLarge) || errors.Is(err, ErrFileTooLarge) || len(results) > 0 {
+		t.Errorf("contentMatchers.matchStructured() = %v, %v, want %v", results, err, ErrStructuredTooLarge)
+	}
+	// the lines are still matched with the whole content
+	if got, err := io.ReadAll(rest); err != nil || string(got) != content {
+		t.Errorf("contentMatchers.matchStructured() rest = %q, %v, want %q", got, err, content)
+	}
+}
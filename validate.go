@@ -0,0 +1,161 @@
+package mres
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	// ValidationValid is set on the matches a validator confirmed
+	ValidationValid ValidationStatus = "valid"
+	// ValidationInvalid matches are false positives, they are not reported
+	ValidationInvalid ValidationStatus = "invalid"
+	// ValidationUnknown is set when a validator could not decide, the match is reported
+	ValidationUnknown ValidationStatus = "unknown"
+)
+
+type (
+	// ValidationStatus is the outcome of the validator of a content rule
+	ValidationStatus string
+
+	// Validator decides if a content match is kept. It gets the match before it is redacted, MatchString is
+	// the capture group of ContentMatchExp.ValidatorGroup if it is set. It is called concurrently by the workers.
+	Validator func(r ContentMatchResult) ValidationStatus
+)
+
+// builtinValidators are the validators a content rule can refer to by name in ContentMatchExp.Validator
+var builtinValidators = map[string]Validator{
+	"luhn": ValidateLuhn,
+	"iban": ValidateIBAN,
+	"jwt":  ValidateJWT,
+}
+
+// SetValidator sets the validator of the content rule with the id, replacing its built-in validator if any.
+// A nil validator removes it. Custom validators are not part of the rules identifying a ScanCache,
+// reset the cache when one changes. Like the other options, it must not be called while a scan is running.
+func (s *Scanner) SetValidator(expID string, validator Validator) error {
+	found := false
+	for i := range s.contentMatchers {
+		if s.contentMatchers[i].ID == expID {
+			s.contentMatchers[i].validator = validator
+			found = true
+		}
+	}
+	if !found {
+		return fmt.Errorf("%w: no content rule with id: %s", ErrInvalidArgument, expID)
+	}
+	return nil
+}
+
+// validate runs the validator of the matcher on the match of content at loc, the submatch indexes of the match,
+// or on its validator group. A validator group which did not participate in the match is invalid.
+func (m contentMatcher) validate(result ContentMatchResult, content []byte, loc []int) ValidationStatus {
+	if m.validatorGroup > 0 {
+		start, end := loc[2*m.validatorGroup], loc[2*m.validatorGroup+1]
+		if start < 0 {
+			return ValidationInvalid
+		}
+		result.MatchString = string(content[start:end])
+	}
+	return m.validator(result)
+}
+
+// getBuiltinValidator returns the built-in validator with the name, nil if name is not set
+func getBuiltinValidator(name string) (Validator, error) {
+	if name == "" {
+		return nil, nil
+	}
+	validator, ok := builtinValidators[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown validator: %s", ErrInvalidArgument, name)
+	}
+	return validator, nil
+}
+
+// ValidateLuhn validates card numbers with the Luhn checksum, spaces and dashes between the digits are allowed
+func ValidateLuhn(r ContentMatchResult) ValidationStatus {
+	digits := strings.NewReplacer(" ", "", "-", "").Replace(r.MatchString)
+	if len(digits) < 12 || len(digits) > 19 {
+		return ValidationInvalid
+	}
+	sum := 0
+	for i := 0; i < len(digits); i++ {
+		d := int(digits[len(digits)-1-i] - '0')
+		if d < 0 || d > 9 {
+			return ValidationInvalid
+		}
+		if i%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	if sum%10 != 0 {
+		return ValidationInvalid
+	}
+	return ValidationValid
+}
+
+// ValidateIBAN validates IBANs with their mod 97 check digits, spaces between the characters are allowed
+func ValidateIBAN(r ContentMatchResult) ValidationStatus {
+	iban := strings.ToUpper(strings.ReplaceAll(r.MatchString, " ", ""))
+	if len(iban) < 15 || len(iban) > 34 {
+		return ValidationInvalid
+	}
+	for i := 0; i < 4; i++ {
+		isLetter := iban[i] >= 'A' && iban[i] <= 'Z'
+		isDigit := iban[i] >= '0' && iban[i] <= '9'
+		if i < 2 && !isLetter || i >= 2 && !isDigit {
+			return ValidationInvalid
+		}
+	}
+	// the country code and the check digits are moved to the end and the letters become 10 to 35
+	remainder := 0
+	for _, c := range iban[4:] + iban[:4] {
+		switch {
+		case c >= '0' && c <= '9':
+			remainder = (remainder*10 + int(c-'0')) % 97
+		case c >= 'A' && c <= 'Z':
+			remainder = (remainder*100 + int(c-'A') + 10) % 97
+		default:
+			return ValidationInvalid
+		}
+	}
+	if remainder != 1 {
+		return ValidationInvalid
+	}
+	return ValidationValid
+}
+
+// ValidateJWT checks the match is a JSON Web Token with a JSON header naming its algorithm and a JSON payload.
+// The signature is not verified.
+func ValidateJWT(r ContentMatchResult) ValidationStatus {
+	parts := strings.Split(r.MatchString, ".")
+	if len(parts) != 3 {
+		return ValidationInvalid
+	}
+	header := struct {
+		Alg string `json:"alg"`
+	}{}
+	if !decodeJWTPart(parts[0], &header) || header.Alg == "" {
+		return ValidationInvalid
+	}
+	payload := map[string]interface{}{}
+	if !decodeJWTPart(parts[1], &payload) {
+		return ValidationInvalid
+	}
+	return ValidationValid
+}
+
+// decodeJWTPart decodes a base64url encoded JSON object
+func decodeJWTPart(part string, v interface{}) bool {
+	content, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(part, "="))
+	if err != nil {
+		return false
+	}
+	return json.Unmarshal(content, v) == nil
+}
@@ -0,0 +1,422 @@
+package mres
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// readDirBatch is the number of directory entries read at once, so huge directories are not loaded fully
+const readDirBatch = 1024
+
+type (
+	// WalkOptions controls how the paths to scan are walked, set it with Scanner.SetWalkOptions
+	WalkOptions struct {
+		// FollowSymlinks resolves symbolic links and scans their targets. Link cycles and targets
+		// which are already visited are skipped, results carry the link path in LinkPath.
+		FollowSymlinks bool `json:"follow_symlinks,omitempty"`
+		// MaxDepth limits how deep the walk descends below each path to scan, 0 means no limit.
+		// Files directly inside a path to scan are at depth 1.
+		MaxDepth int `json:"max_depth,omitempty"`
+		// OneFileSystem skips directories on a different filesystem than the path to scan (find -xdev)
+		OneFileSystem bool `json:"one_file_system,omitempty"`
+		// SkipHidden skips files and directories whose name starts with a dot
+		SkipHidden bool `json:"skip_hidden,omitempty"`
+		// SkipSpecialFiles skips devices, named pipes, sockets and other irregular files
+		SkipSpecialFiles bool `json:"skip_special_files,omitempty"`
+		// Workers is the number of goroutines reading directories concurrently.
+		// If not set, the worker count passed to the scan is used.
+		Workers int `json:"workers,omitempty"`
+	}
+
+	scanJob struct {
+		path     string
+		linkPath string
+		// cacheable is set for the OS files walked by Scan, their results can be cached
+		cacheable bool
+		// blob and commit are set for the jobs of git history scans
+		blob   string
+		commit *CommitInfo
+	}
+
+	// dirTask is a directory waiting to be read by the walker
+	dirTask struct {
+		path     string
+		linkPath string
+		depth    int
+		// dev is the device of the path to scan the directory belongs to
+		dev    uint64
+		hasDev bool
+	}
+
+	// walker holds the state of a single walk of all the paths to scan
+	walker struct {
+		s       *Scanner
+		ctx     context.Context
+		jobsC   chan<- scanJob
+		errorsC chan<- error
+		mu      sync.Mutex
+		visited map[string]struct{}
+		queue   *dirQueue
+	}
+
+	// dirQueue is the queue of directories shared by the walker goroutines.
+	// pending counts the queued directories and the ones being read, the walk is over when it is 0.
+	dirQueue struct {
+		mu      sync.Mutex
+		cond    *sync.Cond
+		tasks   []dirTask
+		pending int
+		closed  bool
+	}
+)
+
+// SetWalkOptions sets the options used while walking the paths to scan
+func (s *Scanner) SetWalkOptions(opts WalkOptions) {
+	s.walkOptions = opts
+}
+
+// walkPaths walks the folders concurrently and produces jobs for the workers.
+// depths are the depths the paths start at, nil if they all start at 0.
+func (s *Scanner) walkPaths(ctx context.Context, pathsToScan []string, depths []int, workerCount int, jobsC chan<- scanJob, errorsC chan<- error) {
+	defer func() {
+		s.logger.Debug("Closing jobs channel")
+		close(jobsC)
+	}()
+	w := &walker{
+		s:       s,
+		ctx:     ctx,
+		jobsC:   jobsC,
+		errorsC: errorsC,
+		visited: make(map[string]struct{}),
+		queue:   newDirQueue(),
+	}
+	for i, f := range pathsToScan {
+		s.logger.Debug(fmt.Sprintf("Walking path: %s", f))
+		depth := 0
+		if depths != nil {
+			depth = depths[i]
+		}
+		if !w.walkRoot(f, depth) {
+			s.logger.Debug("Received cancellation. Not walking the paths further")
+			return
+		}
+	}
+	if s.walkOptions.Workers > 0 {
+		workerCount = s.walkOptions.Workers
+	}
+	stopC := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			w.queue.close()
+		case <-stopC:
+		}
+	}()
+	wg := new(sync.WaitGroup)
+	for i := 0; i < workerCount; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.readDirs()
+		}()
+	}
+	wg.Wait()
+	close(stopC)
+	if ctx.Err() != nil {
+		s.logger.Debug("Received cancellation. Not walking the paths further")
+	}
+}
+
+// walkRoot queues the path to scan at the depth, it returns false if the scan was cancelled
+func (w *walker) walkRoot(path string, depth int) bool {
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return w.sendError(err)
+	}
+	task := dirTask{path: path, depth: depth}
+	if id, ok := getFileID(fi); ok {
+		task.dev = id.dev
+		task.hasDev = true
+	}
+	switch {
+	case fi.IsDir():
+		if w.enterDir(task, fi) {
+			w.queue.push(task)
+		}
+		return true
+	case fi.Mode()&os.ModeSymlink == os.ModeSymlink:
+		if !w.s.walkOptions.FollowSymlinks {
+			return true
+		}
+		// the filesystem of a linked path to scan is the one of its target
+		if target, err := os.Stat(path); err == nil {
+			if id, ok := getFileID(target); ok {
+				task.dev = id.dev
+			}
+		}
+		return w.followLink(task, path, "")
+	default:
+		if w.s.walkOptions.SkipSpecialFiles && isSpecialMode(fi.Mode()) {
+			return true
+		}
+		return w.sendFile(path, "", fi)
+	}
+}
+
+// readDirs reads the queued directories until the walk is over or cancelled
+func (w *walker) readDirs() {
+	for {
+		task, ok := w.queue.pop()
+		if !ok {
+			return
+		}
+		if !w.readDir(task) {
+			w.queue.close()
+		}
+		w.queue.done()
+	}
+}
+
+// readDir reads the directory entries in batches, it returns false if the scan was cancelled
+func (w *walker) readDir(task dirTask) bool {
+	dir, err := os.Open(task.path)
+	if err != nil {
+		return w.sendError(err)
+	}
+	defer dir.Close()
+	for {
+		entries, err := dir.ReadDir(readDirBatch)
+		for _, e := range entries {
+			if !w.processEntry(task, e) {
+				return false
+			}
+		}
+		if err == io.EOF {
+			return true
+		}
+		if err != nil {
+			return w.sendError(err)
+		}
+	}
+}
+
+// processEntry produces a job or queues a directory for the entry, it returns false if the scan was cancelled
+func (w *walker) processEntry(parent dirTask, e os.DirEntry) bool {
+	if w.ctx.Err() != nil {
+		return false
+	}
+	opts := w.s.walkOptions
+	if opts.SkipHidden && isHidden(e.Name()) {
+		return true
+	}
+	task := parent
+	task.path = filepath.Join(parent.path, e.Name())
+	task.depth = parent.depth + 1
+	if parent.linkPath != "" {
+		task.linkPath = filepath.Join(parent.linkPath, e.Name())
+	}
+	switch {
+	case e.IsDir():
+		var fi os.FileInfo
+		if opts.OneFileSystem || opts.FollowSymlinks {
+			info, err := e.Info()
+			if err != nil {
+				return w.sendError(err)
+			}
+			fi = info
+		}
+		if w.enterDir(task, fi) {
+			w.queue.push(task)
+		}
+		return true
+	case e.Type()&os.ModeSymlink == os.ModeSymlink:
+		if !opts.FollowSymlinks {
+			return true
+		}
+		return w.followLink(task, task.path, task.linkPath)
+	default:
+		if opts.SkipSpecialFiles && isSpecialMode(e.Type()) {
+			w.s.logger.Debug(fmt.Sprintf("Skipping special file: %s", task.path))
+			return true
+		}
+		var fi os.FileInfo
+		if opts.FollowSymlinks {
+			info, err := e.Info()
+			if err != nil {
+				return w.sendError(err)
+			}
+			fi = info
+		}
+		return w.sendFile(task.path, task.linkPath, fi)
+	}
+}
+
+// enterDir decides if the walk should descend into the directory of the task,
+// fi is only needed when WalkOptions.OneFileSystem or WalkOptions.FollowSymlinks is set
+func (w *walker) enterDir(task dirTask, fi os.FileInfo) bool {
+	opts := w.s.walkOptions
+	if opts.MaxDepth > 0 && task.depth >= opts.MaxDepth {
+		return false
+	}
+	if opts.OneFileSystem && task.hasDev {
+		if id, ok := getFileID(fi); ok && id.dev != task.dev {
+			w.s.logger.Debug(fmt.Sprintf("Skipping directory on another filesystem: %s", task.path))
+			return false
+		}
+	}
+	if opts.FollowSymlinks && !w.markVisited(task.path, fi) {
+		w.s.logger.Debug(fmt.Sprintf("Skipping already visited directory: %s", task.path))
+		return false
+	}
+	return true
+}
+
+// followLink resolves the symlink at path and queues its target, it returns false if the scan was cancelled
+func (w *walker) followLink(task dirTask, path string, linkPath string) bool {
+	if linkPath == "" {
+		linkPath = path
+	}
+	target, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return w.sendError(err)
+	}
+	fi, err := os.Stat(target)
+	if err != nil {
+		return w.sendError(err)
+	}
+	if fi.IsDir() {
+		task.path = target
+		task.linkPath = linkPath
+		if w.enterDir(task, fi) {
+			w.queue.push(task)
+		}
+		return true
+	}
+	if w.s.walkOptions.SkipSpecialFiles && isSpecialMode(fi.Mode()) {
+		w.s.logger.Debug(fmt.Sprintf("Skipping special file: %s -> %s", linkPath, target))
+		return true
+	}
+	return w.sendFile(target, linkPath, fi)
+}
+
+// sendFile produces a job for the file, fi is only needed when WalkOptions.FollowSymlinks is set.
+// It returns false if the scan was cancelled.
+func (w *walker) sendFile(path string, linkPath string, fi os.FileInfo) bool {
+	if w.s.walkOptions.FollowSymlinks && !w.markVisited(path, fi) {
+		w.s.logger.Debug(fmt.Sprintf("Skipping already visited file: %s", path))
+		return true
+	}
+	select {
+	case <-w.ctx.Done():
+		return false
+	case w.jobsC <- scanJob{path: path, linkPath: linkPath, cacheable: true}:
+		return true
+	}
+}
+
+// sendError reports a walk error, it returns false if the scan was cancelled
+func (w *walker) sendError(err error) bool {
+	select {
+	case <-w.ctx.Done():
+		return false
+	case w.errorsC <- err: // normal errors send it to error channel
+		return true
+	}
+}
+
+// markVisited records the file as visited and returns false if it was already visited
+func (w *walker) markVisited(path string, fi os.FileInfo) bool {
+	key := ""
+	if id, ok := getFileID(fi); ok {
+		key = fmt.Sprintf("%d:%d", id.dev, id.ino)
+	} else if abs, err := filepath.Abs(path); err == nil {
+		key = abs
+	} else {
+		key = path
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, ok := w.visited[key]; ok {
+		return false
+	}
+	w.visited[key] = struct{}{}
+	return true
+}
+
+func newDirQueue() *dirQueue {
+	q := &dirQueue{}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+func (q *dirQueue) push(task dirTask) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return
+	}
+	q.tasks = append(q.tasks, task)
+	q.pending++
+	q.cond.Signal()
+}
+
+// pop waits for a directory to read, it returns false once the walk is over or the queue is closed.
+// Directories are taken last in first out so the queue stays small on deep trees.
+func (q *dirQueue) pop() (dirTask, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.tasks) == 0 && q.pending > 0 && !q.closed {
+		q.cond.Wait()
+	}
+	if len(q.tasks) == 0 || q.closed {
+		return dirTask{}, false
+	}
+	task := q.tasks[len(q.tasks)-1]
+	q.tasks = q.tasks[:len(q.tasks)-1]
+	return task, true
+}
+
+// done marks a popped directory as read
+func (q *dirQueue) done() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.pending--
+	if q.pending == 0 {
+		q.cond.Broadcast()
+	}
+}
+
+// close stops the walk, the queued directories are dropped
+func (q *dirQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+	q.tasks = nil
+	q.cond.Broadcast()
+}
+
+func isHidden(name string) bool {
+	return len(name) > 1 && name[0] == '.' && name != ".."
+}
+
+func isSpecialMode(mode os.FileMode) bool {
+	return mode&(os.ModeDevice|os.ModeCharDevice|os.ModeNamedPipe|os.ModeSocket|os.ModeIrregular) != 0
+}
+
+// fileResult annotates a file match result of the job
+func (j scanJob) fileResult(r FileMatchResult) FileMatchResult {
+	r.LinkPath = j.linkPath
+	r.Commit = j.commit
+	return r
+}
+
+// contentResult annotates a content match result of the job
+func (j scanJob) contentResult(r ContentMatchResult) ContentMatchResult {
+	r.LinkPath = j.linkPath
+	r.Commit = j.commit
+	return r
+}
@@ -0,0 +1,185 @@
+package mres
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// newTestTree creates the files with their contents under a temporary directory and returns its path,
+// the directory is removed once the test is done
+func newTestTree(t *testing.T, files map[string]string) string {
+	root := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("error while creating dir. err: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("error while writing file. err: %v", err)
+		}
+	}
+	return root
+}
+
+func TestScanner_FollowSymlinks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need privileges on windows")
+	}
+	root := newTestTree(t, map[string]string{
+		"real/a.txt":  "secret",
+		"other/b.txt": "secret",
+	})
+	links := map[string]string{
+		"real/loop":    "..",                                // cycle back to root
+		"real/a-link":  "a.txt",                             // same target as real/a.txt
+		"other-link":   filepath.Join(root, "other"),        // directory link
+		"dangling":     filepath.Join(root, "not-existing"), // broken link
+		"real/b-link":  filepath.Join(root, "other", "b.txt"),
+		"other/b-link": "b.txt",
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(root, name)); err != nil {
+			t.Fatalf("error while creating symlink. err: %v", err)
+		}
+	}
+	exps := Expressions{ContentMatchExps: []ContentMatchExp{{ID: "secret", Exp: "secret"}}}
+	tests := []struct {
+		name       string
+		opts       WalkOptions
+		wantCount  int
+		wantErrors int
+	}{
+		{
+			name:       "symlinks skipped",
+			opts:       WalkOptions{},
+			wantCount:  2,
+			wantErrors: 0,
+		},
+		{
+			name:       "symlinks followed",
+			opts:       WalkOptions{FollowSymlinks: true},
+			wantCount:  2,
+			wantErrors: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, errs := NewScanner(exps)
+			if len(errs) > 0 {
+				t.Fatalf("error while setting up scanner. err: %v", errs)
+			}
+			s.SetWalkOptions(tt.opts)
+			got, gotErrs := s.Scan(context.TODO(), []string{root}, 2)
+			if len(got.ContentMatches) != tt.wantCount {
+				t.Errorf("Scanner.Scan() matches = %v, want %v", got.ContentMatches, tt.wantCount)
+			}
+			if len(gotErrs) != tt.wantErrors {
+				t.Errorf("Scanner.Scan() errors = %v, want %v", gotErrs, tt.wantErrors)
+			}
+		})
+	}
+}
+
+func TestScanner_FollowSymlinksLinkPath(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need privileges on windows")
+	}
+	target := newTestTree(t, map[string]string{"conf/app.conf": "secret"})
+	root := newTestTree(t, map[string]string{})
+	if err := os.Symlink(filepath.Join(target, "conf"), filepath.Join(root, "conf")); err != nil {
+		t.Fatalf("error while creating symlink. err: %v", err)
+	}
+	s, _ := NewScanner(Expressions{FileMatchExps: []FileMatchExp{{ID: "conf", Exp: `\.conf$`}}})
+	s.SetWalkOptions(WalkOptions{FollowSymlinks: true})
+	got, errs := s.Scan(context.TODO(), []string{root}, 1)
+	if len(errs) > 0 {
+		t.Fatalf("Scanner.Scan() errors = %v", errs)
+	}
+	want := filepath.Join(root, "conf", "app.conf")
+	if len(got.FileMatches) != 1 || got.FileMatches[0].LinkPath != want {
+		t.Errorf("Scanner.Scan() file matches = %v, want link path %v", got.FileMatches, want)
+	}
+}
+
+func TestScanner_WalkOptions(t *testing.T) {
+	root := newTestTree(t, map[string]string{
+		"a.txt":             "secret",
+		".hidden.txt":       "secret",
+		".git/config":       "secret",
+		"d1/b.txt":          "secret",
+		"d1/d2/c.txt":       "secret",
+		"d1/d2/d3/d.txt":    "secret",
+		"d1/.cache/e.txt":   "secret",
+		"d1/d2/.hidden.txt": "secret",
+	})
+	exps := Expressions{FileMatchExps: []FileMatchExp{{ID: "txt", Exp: `\.txt$|config$`}}}
+	tests := []struct {
+		name      string
+		opts      WalkOptions
+		wantCount int
+	}{
+		{name: "defaults", opts: WalkOptions{}, wantCount: 8},
+		{name: "max depth 1", opts: WalkOptions{MaxDepth: 1}, wantCount: 2},
+		{name: "max depth 2", opts: WalkOptions{MaxDepth: 2}, wantCount: 4},
+		{name: "skip hidden", opts: WalkOptions{SkipHidden: true}, wantCount: 4},
+		{name: "skip hidden with max depth", opts: WalkOptions{SkipHidden: true, MaxDepth: 3}, wantCount: 3},
+		{name: "one file system", opts: WalkOptions{OneFileSystem: true}, wantCount: 8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := NewScanner(exps)
+			s.SetWalkOptions(tt.opts)
+			got, errs := s.Scan(context.TODO(), []string{root}, 2)
+			if len(errs) > 0 {
+				t.Fatalf("Scanner.Scan() errors = %v", errs)
+			}
+			if len(got.FileMatches) != tt.wantCount {
+				t.Errorf("Scanner.Scan() file matches = %v, want %v", got.FileMatches, tt.wantCount)
+			}
+		})
+	}
+}
+
+func TestScanner_ParallelWalk(t *testing.T) {
+	files := make(map[string]string)
+	for i := 0; i < 20; i++ {
+		for j := 0; j < 20; j++ {
+			files[filepath.Join(fmt.Sprintf("d%d", i), fmt.Sprintf("s%d", j), "f.txt")] = "secret"
+		}
+	}
+	root := newTestTree(t, files)
+	exps := Expressions{ContentMatchExps: []ContentMatchExp{{ID: "secret", Exp: "secret"}}}
+	tests := []struct {
+		name      string
+		opts      WalkOptions
+		cancel    bool
+		wantCount int
+	}{
+		{name: "single walker", opts: WalkOptions{Workers: 1}, wantCount: 400},
+		{name: "many walkers", opts: WalkOptions{Workers: 8}, wantCount: 400},
+		{name: "walkers from worker count", opts: WalkOptions{}, wantCount: 400},
+		{name: "cancelled", opts: WalkOptions{Workers: 8}, cancel: true, wantCount: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := NewScanner(exps)
+			s.SetWalkOptions(tt.opts)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancel {
+				cancel()
+			}
+			got, errs := s.Scan(ctx, []string{root}, 4)
+			if len(errs) > 0 {
+				t.Fatalf("Scanner.Scan() errors = %v", errs)
+			}
+			if len(got.ContentMatches) != tt.wantCount {
+				t.Errorf("Scanner.Scan() content matches = %v, want %v", len(got.ContentMatches), tt.wantCount)
+			}
+		})
+	}
+}
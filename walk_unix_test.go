@@ -0,0 +1,42 @@
+//go:build !windows
+// +build !windows
+
+package mres
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestScanner_SkipSpecialFiles(t *testing.T) {
+	root := newTestTree(t, map[string]string{"a.txt": "secret"})
+	defer os.RemoveAll(root)
+	if err := syscall.Mkfifo(filepath.Join(root, "pipe"), 0644); err != nil {
+		t.Skipf("cannot create named pipe. err: %v", err)
+	}
+	exps := Expressions{FileMatchExps: []FileMatchExp{{ID: "all", Exp: "."}}}
+	tests := []struct {
+		name      string
+		opts      WalkOptions
+		wantCount int
+	}{
+		{name: "special files included", opts: WalkOptions{}, wantCount: 2},
+		{name: "special files skipped", opts: WalkOptions{SkipSpecialFiles: true}, wantCount: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := NewScanner(exps)
+			s.SetWalkOptions(tt.opts)
+			got, errs := s.Scan(context.TODO(), []string{root}, 1)
+			if len(errs) > 0 {
+				t.Fatalf("Scanner.Scan() errors = %v", errs)
+			}
+			if len(got.FileMatches) != tt.wantCount {
+				t.Errorf("Scanner.Scan() file matches = %v, want %v", got.FileMatches, tt.wantCount)
+			}
+		})
+	}
+}
@@ -0,0 +1,367 @@
+package mres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const defaultWatchDebounce = 500 * time.Millisecond
+
+type (
+	// WatchOptions controls watch scans, zero values use the defaults
+	WatchOptions struct {
+		// Debounce is how long the changes have to settle before the changed files are scanned again,
+		// defaults to 500ms
+		Debounce time.Duration `json:"debounce,omitempty"`
+	}
+
+	// watchSession holds the state of a watch scan. The findings are kept by the path of the watched file,
+	// archive entries belong to their archive.
+	watchSession struct {
+		s                    *Scanner
+		ctx                  context.Context
+		roots                []string
+		workerCount          int
+		watcher              *fsnotify.Watcher
+		fileMatches          map[string][]FileMatchResult
+		contentMatches       map[string][]ContentMatchResult
+		onFileMatchResult    func(r FileMatchResult)
+		onContentMatchResult func(r ContentMatchResult)
+		onError              func(e error)
+	}
+)
+
+// WatchWithCallback scans the paths like ScanWithCallback and then watches them for changes until ctx is cancelled.
+// Created, modified and renamed files are scanned again once the changes settle, the new matches are reported
+// like in a scan and the matches which are gone are reported again with Resolved set. Matches are compared by
+// the fingerprint of the matches so a match moving to another line is not reported again.
+// Matches of deleted files and directories are resolved.
+func (s *Scanner) WatchWithCallback(
+	ctx context.Context, pathsToScan []string, workerCount int, opts WatchOptions,
+	onFileMatchResult func(r FileMatchResult),
+	onContentMatchResult func(r ContentMatchResult),
+	onError func(e error)) {
+	if len(pathsToScan) == 0 {
+		onError(ErrInvalidArgument)
+		return
+	}
+	if opts.Debounce <= 0 {
+		opts.Debounce = defaultWatchDebounce
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		onError(fmt.Errorf("error: %w while starting the watcher", err))
+		return
+	}
+	defer watcher.Close()
+	w := &watchSession{
+		s:                    s,
+		ctx:                  ctx,
+		workerCount:          workerCount,
+		watcher:              watcher,
+		fileMatches:          make(map[string][]FileMatchResult),
+		contentMatches:       make(map[string][]ContentMatchResult),
+		onFileMatchResult:    onFileMatchResult,
+		onContentMatchResult: onContentMatchResult,
+		onError:              onError,
+	}
+	for _, root := range pathsToScan {
+		w.roots = append(w.roots, filepath.Clean(root))
+	}
+	// the watches are added before the first scan so no change is missed in between
+	for _, root := range w.roots {
+		w.addWatches(root)
+	}
+	w.rescan(w.roots)
+	w.loop(opts.Debounce)
+}
+
+// loop collects the changed paths and scans them again once no change was seen for debounce
+func (w *watchSession) loop(debounce time.Duration) {
+	dirty := make(map[string]struct{})
+	timer := time.NewTimer(debounce)
+	timer.Stop()
+	defer timer.Stop()
+	for {
+		select {
+		case <-w.ctx.Done():
+			w.s.logger.Debug("Received cancellation. Stopping the watch")
+			return
+		case e, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			path := filepath.Clean(e.Name)
+			if e.Op == fsnotify.Chmod || !w.inScope(path) {
+				continue
+			}
+			if w.s.walkOptions.SkipHidden && isHidden(filepath.Base(path)) {
+				continue
+			}
+			w.s.logger.Debug(fmt.Sprintf("Watch event: %s", e))
+			dirty[path] = struct{}{}
+			timer.Reset(debounce)
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			w.onError(err)
+			// the changes are unknown once events are dropped
+			if errors.Is(err, fsnotify.ErrEventOverflow) {
+				for _, root := range w.roots {
+					dirty[root] = struct{}{}
+				}
+				timer.Reset(debounce)
+			}
+		case <-timer.C:
+			paths := collapsePaths(dirty)
+			dirty = make(map[string]struct{})
+			w.rescan(paths)
+		}
+	}
+}
+
+// rescan scans the paths again and reports the difference with the matches found before.
+// Paths which do not exist anymore resolve all their matches. WalkOptions.MaxDepth is counted from
+// the watched path the paths belong to.
+func (w *watchSession) rescan(paths []string) {
+	maxDepth := w.s.walkOptions.MaxDepth
+	existing := make([]string, 0, len(paths))
+	depths := make([]int, 0, len(paths))
+	for _, path := range paths {
+		fi, err := os.Lstat(path)
+		if err != nil {
+			continue
+		}
+		depth := relDepth(w.rootOf(path), path)
+		if maxDepth > 0 && depth > maxDepth {
+			continue
+		}
+		if fi.IsDir() {
+			// new and renamed directories are not watched yet
+			w.addWatches(path)
+		}
+		existing = append(existing, path)
+		depths = append(depths, depth)
+	}
+	fileMatches := make(map[string][]FileMatchResult)
+	contentMatches := make(map[string][]ContentMatchResult)
+	if len(existing) > 0 {
+		w.s.scanPathsWithCallback(w.ctx, existing, depths, w.workerCount,
+			func(r FileMatchResult) {
+				key := watchKey(r.FilePath, r.LinkPath)
+				fileMatches[key] = append(fileMatches[key], r)
+			},
+			func(r ContentMatchResult) {
+				key := watchKey(r.FilePath, r.LinkPath)
+				contentMatches[key] = append(contentMatches[key], r)
+			},
+			w.onError)
+	}
+	if w.ctx.Err() != nil {
+		// the results of a cancelled scan are partial, nothing is resolved
+		return
+	}
+	keys := make(map[string]struct{})
+	for key := range w.fileMatches {
+		if underAny(key, paths) {
+			keys[key] = struct{}{}
+		}
+	}
+	for key := range w.contentMatches {
+		if underAny(key, paths) {
+			keys[key] = struct{}{}
+		}
+	}
+	for key := range fileMatches {
+		keys[key] = struct{}{}
+	}
+	for key := range contentMatches {
+		keys[key] = struct{}{}
+	}
+	for _, key := range sortedKeys(keys) {
+		w.updateFileMatches(key, fileMatches[key])
+		w.updateContentMatches(key, contentMatches[key])
+	}
+}
+
+// updateFileMatches reports the difference between the known file matches of the file and the new ones
+func (w *watchSession) updateFileMatches(key string, results []FileMatchResult) {
+	old := make(map[string]int)
+	for _, r := range w.fileMatches[key] {
+		old[r.Fingerprint()]++
+	}
+	for _, r := range results {
+		if id := r.Fingerprint(); old[id] > 0 {
+			old[id]--
+			continue
+		}
+		w.onFileMatchResult(r)
+	}
+	for _, r := range w.fileMatches[key] {
+		if id := r.Fingerprint(); old[id] > 0 {
+			old[id]--
+			r.Resolved = true
+			w.onFileMatchResult(r)
+		}
+	}
+	if len(results) == 0 {
+		delete(w.fileMatches, key)
+		return
+	}
+	w.fileMatches[key] = results
+}
+
+// updateContentMatches reports the difference between the known content matches of the file and the new ones
+func (w *watchSession) updateContentMatches(key string, results []ContentMatchResult) {
+	old := make(map[string]int)
+	for _, r := range w.contentMatches[key] {
+		old[r.Fingerprint()]++
+	}
+	for _, r := range results {
+		if id := r.Fingerprint(); old[id] > 0 {
+			old[id]--
+			continue
+		}
+		w.onContentMatchResult(r)
+	}
+	for _, r := range w.contentMatches[key] {
+		if id := r.Fingerprint(); old[id] > 0 {
+			old[id]--
+			r.Resolved = true
+			w.onContentMatchResult(r)
+		}
+	}
+	if len(results) == 0 {
+		delete(w.contentMatches, key)
+		return
+	}
+	w.contentMatches[key] = results
+}
+
+// addWatches watches the directory and its sub directories, the parent directory is watched for files
+// so they are still seen after being replaced by a rename
+func (w *watchSession) addWatches(path string) {
+	opts := w.s.walkOptions
+	fi, err := os.Stat(path)
+	if err != nil {
+		w.onError(err)
+		return
+	}
+	if !fi.IsDir() {
+		if err := w.watcher.Add(filepath.Dir(path)); err != nil {
+			w.onError(fmt.Errorf("error: %w while watching: %s", err, filepath.Dir(path)))
+		}
+		return
+	}
+	root := w.rootOf(path)
+	err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// directories removed while walking are resolved by their events
+			if !errors.Is(err, fs.ErrNotExist) {
+				w.onError(err)
+			}
+			return nil
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if p != path && opts.SkipHidden && isHidden(d.Name()) {
+			return filepath.SkipDir
+		}
+		if opts.MaxDepth > 0 && relDepth(root, p) >= opts.MaxDepth {
+			return filepath.SkipDir
+		}
+		if err := w.watcher.Add(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			w.onError(fmt.Errorf("error: %w while watching: %s", err, p))
+		}
+		return nil
+	})
+	if err != nil {
+		w.onError(err)
+	}
+}
+
+// inScope reports if the path is one of the watched paths or inside one
+func (w *watchSession) inScope(path string) bool {
+	return underAny(path, w.roots)
+}
+
+// rootOf returns the watched path the path belongs to
+func (w *watchSession) rootOf(path string) string {
+	for _, root := range w.roots {
+		if isUnder(path, root) {
+			return root
+		}
+	}
+	return path
+}
+
+// watchKey is the path of the watched file a result belongs to
+func watchKey(filePath string, linkPath string) string {
+	if linkPath != "" {
+		filePath = linkPath
+	}
+	if i := strings.Index(filePath, archiveSeparator); i >= 0 {
+		filePath = filePath[:i]
+	}
+	return filePath
+}
+
+// isUnder reports if path is root or inside root
+func isUnder(path string, root string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func underAny(path string, roots []string) bool {
+	for _, root := range roots {
+		if isUnder(path, root) {
+			return true
+		}
+	}
+	return false
+}
+
+// relDepth is the depth of path below root, root itself is at depth 0
+func relDepth(root string, path string) int {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == "." {
+		return 0
+	}
+	return strings.Count(rel, string(filepath.Separator)) + 1
+}
+
+// collapsePaths returns the sorted paths without the ones inside another of the paths
+func collapsePaths(paths map[string]struct{}) []string {
+	sorted := sortedKeys(paths)
+	collapsed := make([]string, 0, len(sorted))
+	for _, path := range sorted {
+		if underAny(path, collapsed) {
+			continue
+		}
+		collapsed = append(collapsed, path)
+	}
+	return collapsed
+}
+
+func sortedKeys(set map[string]struct{}) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,161 @@
+package mres
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestScanner_WatchWithCallback(t *testing.T) {
+	root := newTestTree(t, map[string]string{
+		"config.json":   "{\n  \"token\": \"xorp-aaaa\"\n}\n",
+		"src/main.go":   "package main\n// xorp-bbbb\n",
+		"src/.env":      "TOKEN=xorp-hidden\n",
+		"lib/a/a.go":    "// xorp-cccc\n",
+		"lib/a/b/b.txt": "xorp-dddd\n",
+	})
+	defer os.RemoveAll(root)
+	exps := Expressions{
+		FileMatchExps:    []FileMatchExp{{ID: "go", Exp: `\.go$`}},
+		ContentMatchExps: []ContentMatchExp{{ID: "xorp", Exp: `xorp-\w+`}},
+	}
+	s, _ := NewScanner(exps)
+	s.SetWalkOptions(WalkOptions{SkipHidden: true})
+	ctx, cancel := context.WithCancel(context.Background())
+	eventC := make(chan string, 100)
+	doneC := make(chan struct{})
+	rel := func(path string) string {
+		r, _ := filepath.Rel(root, path)
+		return filepath.ToSlash(r)
+	}
+	state := func(resolved bool) string {
+		if resolved {
+			return "-"
+		}
+		return "+"
+	}
+	go func() {
+		defer close(doneC)
+		s.WatchWithCallback(ctx, []string{root}, 2, WatchOptions{Debounce: 50 * time.Millisecond},
+			func(r FileMatchResult) { eventC <- fmt.Sprintf("%s%s", state(r.Resolved), rel(r.FilePath)) },
+			func(r ContentMatchResult) {
+				eventC <- fmt.Sprintf("%s%s:%d:%s", state(r.Resolved), rel(r.FilePath), r.LineNumber, r.MatchString)
+			},
+			func(e error) { t.Errorf("Scanner.WatchWithCallback() error = %v", e) })
+	}()
+	defer func() {
+		cancel()
+		<-doneC
+	}()
+	// expect waits for the events and fails if others are reported
+	expect := func(step string, want ...string) {
+		got := make([]string, 0)
+		timeout := time.After(5 * time.Second)
+		for len(got) < len(want) {
+			select {
+			case e := <-eventC:
+				got = append(got, e)
+			case <-timeout:
+				t.Fatalf("%s: events = %v, want %v", step, got, want)
+			}
+		}
+		select {
+		case e := <-eventC:
+			got = append(got, e)
+		case <-time.After(200 * time.Millisecond):
+		}
+		sort.Strings(got)
+		sort.Strings(want)
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("%s: events = %v, want %v", step, got, want)
+		}
+	}
+	write := func(name string, content string) {
+		path := filepath.Join(root, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("error while writing file. err: %v", err)
+		}
+	}
+	expect("initial scan",
+		"+config.json:2:xorp-aaaa", "+src/main.go", "+src/main.go:2:xorp-bbbb",
+		"+lib/a/a.go", "+lib/a/a.go:1:xorp-cccc", "+lib/a/b/b.txt:1:xorp-dddd")
+	write("config.json", "{\n  \"token\": \"\"\n}\n")
+	expect("modified file", "-config.json:2:xorp-aaaa")
+	write("src/main.go", "package main\n\n// xorp-bbbb\n// xorp-eeee\n")
+	expect("moved and new match", "+src/main.go:4:xorp-eeee")
+	write("src/.env", "TOKEN=xorp-ffff\n")
+	write("new/deep/c.txt", "xorp-gggg\n")
+	expect("created directory", "+new/deep/c.txt:1:xorp-gggg")
+	if err := os.Rename(filepath.Join(root, "src/main.go"), filepath.Join(root, "src/app.go")); err != nil {
+		t.Fatalf("error while renaming. err: %v", err)
+	}
+	expect("renamed file",
+		"-src/main.go", "-src/main.go:3:xorp-bbbb", "-src/main.go:4:xorp-eeee",
+		"+src/app.go", "+src/app.go:3:xorp-bbbb", "+src/app.go:4:xorp-eeee")
+	if err := os.RemoveAll(filepath.Join(root, "lib")); err != nil {
+		t.Fatalf("error while removing. err: %v", err)
+	}
+	expect("deleted directory", "-lib/a/a.go", "-lib/a/a.go:1:xorp-cccc", "-lib/a/b/b.txt:1:xorp-dddd")
+}
+
+func TestScanner_WatchMaxDepth(t *testing.T) {
+	root := newTestTree(t, map[string]string{
+		"a/a.txt": "xorp-aaaa\n",
+	})
+	defer os.RemoveAll(root)
+	s, _ := NewScanner(Expressions{ContentMatchExps: []ContentMatchExp{{ID: "xorp", Exp: `xorp-\w+`}}})
+	s.SetWalkOptions(WalkOptions{MaxDepth: 2})
+	ctx, cancel := context.WithCancel(context.Background())
+	eventC := make(chan string, 100)
+	doneC := make(chan struct{})
+	go func() {
+		defer close(doneC)
+		s.WatchWithCallback(ctx, []string{root}, 2, WatchOptions{Debounce: 50 * time.Millisecond},
+			func(r FileMatchResult) {},
+			func(r ContentMatchResult) {
+				rel, _ := filepath.Rel(root, r.FilePath)
+				eventC <- filepath.ToSlash(rel)
+			},
+			func(e error) { t.Errorf("Scanner.WatchWithCallback() error = %v", e) })
+	}()
+	defer func() {
+		cancel()
+		<-doneC
+	}()
+	next := func() string {
+		select {
+		case e := <-eventC:
+			return e
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no event reported")
+			return ""
+		}
+	}
+	if got := next(); got != "a/a.txt" {
+		t.Fatalf("initial scan event = %v, want a/a.txt", got)
+	}
+	// a/new is at the depth limit, the files below it are deeper than the limit
+	if err := os.MkdirAll(filepath.Join(root, "a/new/deep"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a/new/c.txt", "a/new/deep/d.txt", "a/b.txt"} {
+		if err := ioutil.WriteFile(filepath.Join(root, name), []byte("xorp-bbbb\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := next(); got != "a/b.txt" {
+		t.Fatalf("created directory event = %v, want a/b.txt", got)
+	}
+	select {
+	case e := <-eventC:
+		t.Errorf("created directory event = %v, want none below the depth limit", e)
+	case <-time.After(300 * time.Millisecond):
+	}
+}